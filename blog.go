@@ -16,12 +16,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -37,91 +41,108 @@ import (
 )
 
 const (
-	blogBaseUrl    = "https://github.com/thiagokokada/blog"
-	blogMainUrl    = blogBaseUrl + "/blob/main/"
-	blogRawUrl     = blogBaseUrl + "/raw/main/"
-	readmeTemplate = `# Blog
-
-Mirror of my blog in https://kokada.capivaras.dev/.
-
-## Posts
-
-[![RSS](https://img.shields.io/badge/RSS-FFA562?style=for-the-badge&logo=rss&logoColor=white)](https://raw.githubusercontent.com/thiagokokada/blog/main/rss.xml)
-
-%s
-`
+	rssFile      = "rss.xml"
+	atomFile     = "atom.xml"
+	jsonFeedFile = "feed.json"
+	rssBadge     = "https://img.shields.io/badge/RSS-FFA562?style=for-the-badge&logo=rss&logoColor=white"
+	tagsDir      = "tags"
 )
 
 type post struct {
 	title    string
 	slug     string
+	aliases  []string
 	contents []byte
 	date     time.Time
+	updated  time.Time
+	tags     []string
+	summary  string
+	lang     string
+	// Only loaded when drafts are included (e.g.: -serve -drafts)
+	draft bool
+	// Where the contents start in the file, for diagnostics
+	file path
+	line int
 }
 type path = string
 type posts = *orderedmap.OrderedMap[path, post]
 
-func must2[T1, T2 any](v1 T1, v2 T2, err error) (T1, T2) {
-	must(err)
-	return v1, v2
-}
-
-func must1[T any](v T, err error) T {
-	must(err)
-	return v
-}
+func extractTitleAndContents(raw []byte) (fm frontMatter, contents []byte, err error) {
+	if len(raw) == 0 {
+		return fm, nil, fmt.Errorf("empty file")
+	}
+	// lineOf returns the line in the file where rest (a suffix of it)
+	// starts, for errors
+	file := raw
+	lineOf := func(rest []byte) int {
+		return 1 + bytes.Count(file[:len(file)-len(rest)], []byte("\n"))
+	}
 
-func must(err error) {
+	// Posts may start with an optional front matter...
+	fm, raw, err = extractFrontMatter(raw)
 	if err != nil {
-		panic(err)
+		return fm, nil, fmt.Errorf("invalid front matter: %w", err)
 	}
-}
-
-func extractTitleAndContents(raw []byte) (title string, contents []byte, err error) {
-	if len(raw) == 0 {
-		return "", nil, fmt.Errorf("empty file")
+	// ...and if it has a title, everything else is the contents
+	if fm.title != "" {
+		contents = bytes.TrimSpace(raw)
+		if len(contents) == 0 {
+			return fm, nil, &lineError{lineOf(raw), fmt.Errorf("could not find content")}
+		}
+		return fm, contents, nil
 	}
+	raw = bytes.TrimLeft(raw, "\n")
 
-	// We are assuming that each file has one title as a H1 header...
-	if raw[0] != '#' {
-		return "", nil, fmt.Errorf("missing '#' (H1) at the start of file")
+	// Otherwise we are assuming that each file has one title as a H1
+	// header...
+	if len(raw) == 0 || raw[0] != '#' {
+		return fm, nil, &lineError{lineOf(raw), fmt.Errorf("missing '#' (H1) at the start of file")}
 	}
+	titleLine := lineOf(raw)
 	// ...followed by a line break and the contents
 	for i, c := range raw {
 		if c != '\n' {
 			continue
 		}
 
-		title = string(bytes.TrimSpace(raw[1:i]))
+		fm.title = string(bytes.TrimSpace(raw[1:i]))
 		contents = bytes.TrimSpace(raw[i:])
 		break
 	}
 	// If we scan the whole file and title or contents are empty, something
 	// is wrong with the file
-	if title == "" {
-		return title, contents, fmt.Errorf("could not find title")
+	if fm.title == "" {
+		return fm, contents, &lineError{titleLine, fmt.Errorf("could not find title")}
 	}
 	if contents == nil {
-		return title, contents, fmt.Errorf("could not find content")
+		return fm, contents, &lineError{titleLine, fmt.Errorf("could not find content")}
 	}
 
-	return title, contents, nil
+	return fm, contents, nil
 }
 
-func getAndValidateSlug(mdFilename, title string) (string, error) {
+func getAndValidateSlug(mdFilename string, fm frontMatter) (string, error) {
+	// A slug set in front matter is canonical, so it just needs to be
+	// valid
+	if fm.slug != "" {
+		if getSlug(fm.slug) != fm.slug {
+			return fm.slug, fmt.Errorf("invalid slug in front matter: %s", fm.slug)
+		}
+		return fm.slug, nil
+	}
+
 	// 01-my-awesome-blog-post.md => my-awesome-blog-post
 	filenameSlug := strings.TrimSuffix(mdFilename[3:], ".md")
 	// My awesome blog post => my-awesome-blog-post
-	titleSlug := getSlug(title)
-
-	// Add any filename that are known to be broken, generally because the
-	// title got changed after publishing
-	knownBrokenFilenames := []string{
-		// Typo, should be "troubleshooting"
-		"01-troubleshoting-zsh-lag-and-solutions-with-nix.md",
-	}
-
-	if filenameSlug != titleSlug && !slices.Contains(knownBrokenFilenames, mdFilename) {
+	titleSlug := getSlug(fm.title)
+
+	// The filename may be using a previous slug, generally because the
+	// title got changed after publishing, so it needs to be declared as
+	// an alias. The filename slug is still canonical in this case (e.g.:
+	// to keep the URL of the published post), so the alias only relaxes
+	// this check and creates no redirect. To really move the post, set the
+	// new slug in front matter and keep the old one as an alias
+	if filenameSlug != titleSlug && !slices.Contains(fm.aliases, filenameSlug) {
 		return filenameSlug, fmt.Errorf(
 			"got conflicting slugs: filename slug: %s, title slug: %s",
 			filenameSlug,
@@ -132,20 +153,50 @@ func getAndValidateSlug(mdFilename, title string) (string, error) {
 	return filenameSlug, nil
 }
 
+// validateSlugs checks that each slug and alias is only used by one post,
+// otherwise the redirects would be ambiguous
+func validateSlugs(ps posts) error {
+	var errs []error
+	seen := map[string]path{}
+	for path, post := range ps.AllFromFront() {
+		for _, slug := range append([]string{post.slug}, post.aliases...) {
+			if getSlug(slug) != slug {
+				errs = append(errs, &postError{file: path, err: fmt.Errorf("invalid slug or alias: %s", slug)})
+				continue
+			}
+			if other, ok := seen[slug]; ok {
+				errs = append(errs, &postError{
+					file: path,
+					err:  fmt.Errorf("duplicated slug or alias: %s, also used by: %s", slug, other),
+				})
+				continue
+			}
+			seen[slug] = path
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func getSlug(s string) string {
 	return slug.Make(s)
 }
 
-func grabPosts(root string) (posts, error) {
-	posts := orderedmap.NewOrderedMap[path, post]()
-
+// grabPosts loads all posts from root, sorted by date. If drafts is true, it
+// will also load drafts (hidden files or posts with draft in front matter)
+// and future posts.
+// Invalid posts don't stop the loading, so all of them are returned in a
+// single error together with the valid posts (e.g.: for -serve)
+func grabPosts(root string, drafts bool) (posts, error) {
+	// Find the posts first, so they can be loaded in parallel while keeping
+	// the order
+	var files []postFile
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Ignore hidden files
-		if d.Name()[0] == '.' {
+		// Ignore hidden files, unless we want drafts
+		if d.Name()[0] == '.' && !drafts {
 			return nil
 		}
 		// Ignore non-Markdown files
@@ -161,126 +212,437 @@ func grabPosts(root string) (posts, error) {
 		}
 
 		// Parse directory name as a date
-		date, err := time.Parse(time.DateOnly, dir)
+		date, err := time.ParseInLocation(time.DateOnly, dir, config.location())
 		if err != nil {
 			log.Printf("[WARN]: ignoring non-date directory: %s\n", path)
 			return nil
 		}
 
-		// Load the contents of the Markdown and try to parse
-		// the title
-		raw, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf(
-				"something went wrong when reading file: %s, error: %w",
-				path,
-				err,
-			)
-		}
+		files = append(files, postFile{path: path, name: d.Name(), date: date})
+		return nil
+	})
+	posts := orderedmap.NewOrderedMap[path, post]()
+	if err != nil {
+		return posts, err
+	}
 
-		title, contents, err := extractTitleAndContents(raw)
-		if err != nil || title == "" || contents == nil {
-			return fmt.Errorf(
-				"something is wrong with file: %s, error: %w",
-				path,
-				err,
-			)
-		}
+	now := time.Now()
+	results := parallelMap(files, func() func(postFile) loadResult {
+		return func(f postFile) loadResult { return loadPost(f, drafts, now) }
+	})
 
-		slug, err := getAndValidateSlug(d.Name(), title)
-		if err != nil {
-			return fmt.Errorf(
-				"something is wrong with slug for file: %s, error: %w",
-				path,
-				err,
-			)
+	var loaded []post
+	var errs []error
+	for i, r := range results {
+		path := files[i].path
+		switch {
+		case r.err != nil:
+			errs = append(errs, newPostError(path, r.err))
+		case r.skip != "":
+			log.Printf("[INFO]: ignoring %s post: %s\n", r.skip, path)
+		default:
+			loaded = append(loaded, r.post)
 		}
+	}
 
-		if date.After(time.Now()) {
-			log.Printf("[INFO]: ignoring future post: %s\n", path)
-			return nil
-		}
+	// The front matter date may move a post to another day, so sort by the
+	// date instead of relying on the file order. Posts with the same date
+	// keep the file order
+	slices.SortStableFunc(loaded, func(a, b post) int { return a.date.Compare(b.date) })
+	for _, post := range loaded {
+		posts.Set(post.file, post)
+	}
 
-		posts.Set(path, post{
-			title:    title,
-			slug:     slug,
-			contents: contents,
-			date:     date,
-		})
+	return posts, errors.Join(append(errs, validateSlugs(posts))...)
+}
 
-		return nil
-	})
+// postFile is a post found in the posts root, but not loaded yet
+type postFile struct {
+	path path
+	name string
+	// From the directory name
+	date time.Time
+}
+
+type loadResult struct {
+	post post
+	// Why the post was skipped (e.g.: draft), if it was
+	skip string
+	err  error
+}
+
+// loadPost loads the contents of the Markdown file and try to parse the
+// title. Drafts and posts after now are skipped, unless drafts is true
+func loadPost(f postFile, drafts bool, now time.Time) loadResult {
+	raw, err := os.ReadFile(f.path)
+	if err != nil {
+		return loadResult{err: fmt.Errorf("could not read file: %w", err)}
+	}
+
+	fm, contents, err := extractTitleAndContents(raw)
+	if err != nil {
+		return loadResult{err: err}
+	}
+
+	slug, err := getAndValidateSlug(strings.TrimPrefix(f.name, "."), fm)
+	if err != nil {
+		return loadResult{err: err}
+	}
+
+	if fm.draft && !drafts {
+		return loadResult{skip: "draft"}
+	}
+
+	// Front matter date overrides the directory one
+	date := f.date
+	if !fm.date.IsZero() {
+		date = fm.date
+	}
+	if date.After(now) && !drafts {
+		return loadResult{skip: "future"}
+	}
+
+	// The contents are trimmed, so find where they start in the file
+	line := 1
+	if i := bytes.Index(raw, contents); i >= 0 {
+		line += bytes.Count(raw[:i], []byte("\n"))
+	}
 
-	return posts, err
+	// An alias equal to the slug only relaxes the validation (see
+	// getAndValidateSlug), since there is nothing to redirect
+	aliases := slices.DeleteFunc(slices.Clone(fm.aliases), func(a string) bool { return a == slug })
+
+	return loadResult{post: post{
+		title:    fm.title,
+		slug:     slug,
+		aliases:  aliases,
+		contents: contents,
+		date:     date,
+		updated:  fm.updated,
+		tags:     normalizeTags(fm.tags),
+		summary:  fm.summary,
+		lang:     fm.lang,
+		draft:    fm.draft || strings.HasPrefix(f.name, "."),
+		file:     f.path,
+		line:     line,
+	}}
 }
 
-func genRss(ps posts) string {
+// feedUrl returns the URL where the feed file will be published, used for
+// self-links
+func feedUrl(file, tag string) (string, error) {
+	if tag != "" {
+		return url.JoinPath(config.RawUrl, tagsDir, tag, file)
+	}
+	return url.JoinPath(config.RawUrl, file)
+}
+
+// genFeed generates the feed shared between all formats. Each item has the
+// rendered post as Content and the post summary as Description
+func genFeed(ps posts, tag string) (*feeds.Feed, error) {
 	feed := &feeds.Feed{
-		Title:       "kokada's blog",
-		Description: "# dd if=/dev/urandom of=/dev/brain0",
-	}
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			NewLinkRewriter(blogMainUrl, nil),
-			extension.GFM,
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("monokai"),
-				highlighting.WithFormatOptions(html.Standalone(true)),
-			),
-		),
+		Title:       config.Title,
+		Description: config.Description,
+		Link:        &feeds.Link{Href: config.BaseUrl},
+		Author:      &feeds.Author{Name: config.Author, Email: config.Email},
+	}
+	if tag != "" {
+		feed.Title += " #" + tag
+		ps = filterByTag(ps, tag)
+	}
+	renderer := fmt.Sprintf(
+		"feed gfm highlighting=standalone style=%s main=%s raw=%s",
+		config.ChromaStyle,
+		config.MainUrl,
+		config.RawUrl,
 	)
+	rendered, err := renderAll(ps, renderer, func() goldmark.Markdown {
+		return goldmark.New(
+			goldmark.WithExtensions(
+				NewLinkRewriter(config.MainUrl, config.RawUrl, nil),
+				extension.GFM,
+				highlighting.NewHighlighting(
+					highlighting.WithStyle(config.ChromaStyle),
+					highlighting.WithFormatOptions(html.Standalone(true)),
+				),
+			),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	var items []*feeds.Item
-	for path, post := range ps.AllFromBack() {
-		link := must1(url.JoinPath(blogMainUrl, path))
-		var buf bytes.Buffer
-		must(md.Convert(post.contents, &buf))
+	for path, post := range rendered.AllFromBack() {
+		link, err := url.JoinPath(config.MainUrl, path)
+		if err != nil {
+			return nil, err
+		}
+
+		updated := post.updated
+		if updated.IsZero() {
+			updated = post.date
+		}
+		if updated.After(feed.Updated) {
+			feed.Updated = updated
+		}
+
 		items = append(items, &feeds.Item{
 			Title:       post.title,
 			Link:        &feeds.Link{Href: link},
 			Created:     post.date,
+			Updated:     updated,
 			Id:          link,
-			Description: buf.String(),
+			Description: post.summary,
+			Content:     string(post.contents),
 		})
 	}
 	feed.Items = items
-	return must1(feed.ToRss())
+	return feed, nil
 }
 
-func genReadme(ps posts) string {
-	var titles []string
-	for path, post := range ps.AllFromBack() {
-		title := fmt.Sprintf(
-			"- [%s](%s) - %s",
-			post.title,
-			path,
-			post.date.Format(time.DateOnly),
-		)
-		titles = append(titles, title)
+func genRss(ps posts, tag string) (string, error) {
+	feed, err := genFeed(ps, tag)
+	if err != nil {
+		return "", err
 	}
-	return fmt.Sprintf(readmeTemplate, strings.Join(titles, "\n"))
+	// RSS readers expect the whole post in description
+	for _, item := range feed.Items {
+		item.Description, item.Content = item.Content, ""
+	}
+	return feed.ToRss()
+}
+
+// atomFeed adds a self-link to the Atom feed, since gorilla/feeds only
+// supports one link per feed
+type atomFeed struct {
+	XMLName xml.Name `xml:"feed"`
+	Links   []feeds.AtomLink
+	*feeds.AtomFeed
+}
+
+func (a *atomFeed) FeedXml() any {
+	return a
+}
+
+func genAtom(ps posts, tag string) (string, error) {
+	f, err := genFeed(ps, tag)
+	if err != nil {
+		return "", err
+	}
+	self, err := feedUrl(atomFile, tag)
+	if err != nil {
+		return "", err
+	}
+	feed := (&feeds.Atom{Feed: f}).AtomFeed()
+	links := []feeds.AtomLink{
+		{Href: feed.Link.Href, Rel: "alternate"},
+		{Href: self, Rel: "self"},
+	}
+	feed.Link = nil
+	return feeds.ToXML(&atomFeed{Links: links, AtomFeed: feed})
+}
+
+func genJsonFeed(ps posts, tag string) (string, error) {
+	f, err := genFeed(ps, tag)
+	if err != nil {
+		return "", err
+	}
+	feed := (&feeds.JSON{Feed: f}).JSONFeed()
+	feed.FeedUrl, err = feedUrl(jsonFeedFile, tag)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToJSON()
 }
 
 func main() {
+	err := run()
+	if err != nil {
+		os.Exit(reportError(os.Stderr, err))
+	}
+}
+
+// run handles all modes, returning the errors to main so they are reported
+// in a single place
+func run() error {
+	configPath := flag.String("config", configFile, "Path to config file (e.g.: to override the defaults)")
 	slugify := flag.String("slugify", "", "Slugify input (e.g.: for blog titles)")
 	rss := flag.Bool("rss", false, "Generate RSS (XML) instead of README.md")
-	prepare := flag.Bool("prepare", false, "Prepare posts to Mataroa (e.g.: validate posts, mostly for debug)")
-	publish := flag.Bool("publish", false, "Publish updates to Maratoa instance")
+	atom := flag.Bool("atom", false, "Generate Atom (XML) instead of README.md")
+	jsonFeed := flag.Bool("json-feed", false, "Generate JSON Feed instead of README.md")
+	tag := flag.String("tag", "", "Only include posts with this tag (e.g.: for feeds)")
+	tags := flag.Bool("tags", false, "List tags with their number of posts")
+	schedule := flag.Bool("schedule", false, "List scheduled (future) posts and when they will be published")
+	htmlDir := flag.String("html", "", "Generate static HTML site in the directory")
+	geminiDir := flag.String("gemini", "", "Generate Gemini capsule in the directory")
+	strict := flag.Bool("strict", false, "Fail if any post has broken links or images (e.g.: for CI)")
+	lint := flag.Bool("lint", false, "Check posts for common issues, exiting with non-zero status code if any is found")
+	checkExternal := flag.Bool("check-links", false, "Check external links in posts, exiting with non-zero status code if any is dead")
+	optimize := flag.Bool("optimize-images", false, "Generate resized images and thumbnails without metadata (e.g.: EXIF)")
+	lintFormat := flag.String("lint-format", "text", "Output format for issues (e.g.: for -lint and -check-links) (text|json)")
+	noCache := flag.Bool("no-cache", false, "Render all posts again, instead of using the render cache")
+	serve := flag.String("serve", "", "Serve a local preview of the blog in the address (e.g.: :8080)")
+	drafts := flag.Bool("drafts", false, "Include drafts and future posts (e.g.: for -serve)")
+	target := flag.String("target", "mataroa", "Target to publish posts (e.g.: for -publish)")
+	prepare := flag.Bool("prepare", false, "Prepare posts to target (e.g.: validate posts, mostly for debug)")
+	publish := flag.Bool("publish", false, "Publish updates to target")
+	force := flag.Bool("force", false, "Publish all posts, even the unchanged ones (e.g.: for -publish)")
+	dryRun := flag.Bool("dry-run", false, "Show what would be published without publishing (e.g.: for -publish)")
+	prune := flag.String("prune", "", "Report, unpublish or delete posts removed locally from target (report|unpublish|delete)")
+	yes := flag.Bool("yes", false, "Do not ask for confirmation (e.g.: for -prune)")
 	flag.Parse()
 
+	// The default config file is optional, but if the user passed one it
+	// should exist
+	var err error
+	config, err = loadConfig(*configPath, *configPath != configFile)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	if *noCache {
+		renderCacheDir = ""
+	}
+
+	// Allow requests in progress to be cancelled with Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	if *slugify != "" {
 		fmt.Println(getSlug(*slugify))
-		os.Exit(0)
+		return nil
 	}
 
-	posts := must1(grabPosts("posts"))
-	if *prepare {
-		prepareToMataroa(posts)
-	} else if *publish {
-		publishToMataroa(posts)
-	} else if *rss {
-		fmt.Print(genRss(posts))
-	} else {
-		fmt.Print(genReadme(posts))
+	if *serve != "" {
+		return servePreview(*serve, config.PostsRoot, *drafts)
+	}
+
+	if *lint {
+		// Also lint drafts, so issues are caught before publishing
+		ps, err := grabPosts(config.PostsRoot, true)
+		if err != nil {
+			return err
+		}
+		issues := lintPosts(ps)
+		err = printIssues(issues, *lintFormat)
+		if err != nil {
+			return err
+		}
+		if len(issues) > 0 {
+			return withExitCode(exitIssues, fmt.Errorf("found %d lint issues", len(issues)))
+		}
+		return nil
+	}
+
+	if *checkExternal {
+		ps, err := grabPosts(config.PostsRoot, true)
+		if err != nil {
+			return err
+		}
+		issues, err := checkExternalLinks(ctx, newLinkChecker(), ps, linkCacheFile)
+		if err != nil {
+			return err
+		}
+		err = printIssues(issues, *lintFormat)
+		if err != nil {
+			return err
+		}
+		dead := 0
+		for _, i := range issues {
+			if i.Rule == lintDeadLink {
+				dead++
+			}
+		}
+		if dead > 0 {
+			return withExitCode(exitIssues, fmt.Errorf("found %d dead links", dead))
+		}
+		return nil
+	}
+
+	if *schedule {
+		ps, err := grabPosts(config.PostsRoot, true)
+		if err != nil {
+			return err
+		}
+		fmt.Print(genSchedule(ps, time.Now()))
+		return nil
+	}
+
+	if *optimize {
+		return optimizeImages(config.PostsRoot)
+	}
+
+	// Only resolve the target when needed, so e.g.: a typo in -target
+	// doesn't break -rss
+	var publisher Publisher
+	if *prepare || *publish || *prune != "" {
+		publisher, err = newPublisher(*target)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+	}
+	if *prune != "" {
+		err = validatePruneAction(publisher, *prune)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+		// Drafts and future posts still exist locally, so they shouldn't
+		// be pruned
+		ps, err := grabPosts(config.PostsRoot, true)
+		if err != nil {
+			return err
+		}
+		return withExitCode(exitRemote, prunePosts(ctx, publisher, ps, *prune, *yes))
+	}
+
+	posts, err := grabPosts(config.PostsRoot, *drafts)
+	if err != nil {
+		return err
+	}
+	if *strict {
+		err = checkLinks(posts)
+		if err != nil {
+			return withExitCode(exitIssues, err)
+		}
+	}
+
+	var out string
+	switch {
+	case *prepare:
+		_, err = publisher.Prepare(posts)
+	case *publish && *dryRun:
+		err = withExitCode(exitRemote, dryRunPublish(ctx, publisher, posts))
+	case *publish:
+		err = withExitCode(exitRemote, publishPosts(ctx, publisher, posts, *force))
+	case *htmlDir != "":
+		err = genSite(posts, config.PostsRoot, *htmlDir)
+	case *geminiDir != "":
+		err = genGemini(posts, config.PostsRoot, *geminiDir)
+	case *tags:
+		out = genTags(posts)
+	case *rss:
+		out, err = genRss(posts, *tag)
+	case *atom:
+		out, err = genAtom(posts, *tag)
+	case *jsonFeed:
+		out, err = genJsonFeed(posts, *tag)
+	default:
+		out, err = genReadme(posts)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// printIssues prints the issues found by -lint or -check-links in stdout
+func printIssues(issues []lintIssue, format string) error {
+	out, err := formatLintIssues(issues, format)
+	if err != nil {
+		return withExitCode(exitUsage, err)
 	}
+	fmt.Print(out)
+	return nil
 }
@@ -0,0 +1,88 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Use the defaults, since the tests shouldn't depend on blog.json
+	var err error
+	config, err = loadConfig("nonexistent.json", false)
+	if err != nil {
+		panic(err)
+	}
+	// Don't write the cache in the repository
+	renderCacheDir = ""
+	os.Exit(m.Run())
+}
+
+// setupPosts writes files (e.g.: "2024-01-01/01-post.md") in the posts root
+// of a temporary directory, that is used as the working directory so the
+// relative paths work as in the repository
+func setupPosts(tb testing.TB, files map[string]string) {
+	tb.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	err = os.Chdir(tb.TempDir())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.Chdir(wd) })
+
+	for name, contents := range files {
+		file := filepath.Join(config.PostsRoot, name)
+		err = os.MkdirAll(filepath.Dir(file), 0o755)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		err = os.WriteFile(file, []byte(contents), 0o644)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// aliasPosts has a post that got moved to a new slug, and another one whose
+// title changed but kept the slug from the filename
+var aliasPosts = map[string]string{
+	"2024-01-01/01-old-title.md":  "---\nslug: new-title\naliases: [old-title]\n---\n# New title\n\nMoved.\n",
+	"2024-01-02/01-typo-titel.md": "---\naliases: [typo-titel]\n---\n# Typo title\n\nKept.\n",
+}
+
+func TestGrabPostsAliases(t *testing.T) {
+	setupPosts(t, aliasPosts)
+
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, _ := ps.Get(filepath.Join(config.PostsRoot, "2024-01-01/01-old-title.md"))
+	if moved.slug != "new-title" || !slices.Equal(moved.aliases, []string{"old-title"}) {
+		t.Errorf("got slug: %s, aliases: %v, want slug: new-title, aliases: [old-title]", moved.slug, moved.aliases)
+	}
+	// The alias only allows the title to differ from the filename
+	kept, _ := ps.Get(filepath.Join(config.PostsRoot, "2024-01-02/01-typo-titel.md"))
+	if kept.slug != "typo-titel" || len(kept.aliases) != 0 {
+		t.Errorf("got slug: %s, aliases: %v, want slug: typo-titel, no aliases", kept.slug, kept.aliases)
+	}
+}
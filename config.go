@@ -0,0 +1,211 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	// Make sure the timezones work even if the system has no tzdata
+	_ "time/tzdata"
+)
+
+const configFile = "blog.json"
+
+// blogConfig allows the binary to be reused by forks of this repository
+// without patching the source code. Secrets (e.g.: tokens) are only read
+// from the environment, so they're not in this struct
+type blogConfig struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	Email       string `json:"email"`
+	// Repository URL, used to generate links to the posts and images in
+	// the feeds
+	BaseUrl string `json:"base_url"`
+	// Derived from BaseUrl (in GitHub format) if empty
+	MainUrl string `json:"main_url"`
+	RawUrl  string `json:"raw_url"`
+	// Where the blog is published, shown in README
+	BlogUrl string `json:"blog_url"`
+	// RSS feed shown in README, derived from RawUrl if empty
+	FeedUrl   string `json:"feed_url"`
+	PostsRoot string `json:"posts_root"`
+	// https://xyproto.github.io/splash/docs/
+	ChromaStyle string `json:"chroma_style"`
+	// IANA timezone (e.g.: America/Sao_Paulo) for the dates without one,
+	// including the directory ones
+	Timezone string `json:"timezone"`
+	// Path to a text/template file, uses the embedded one if empty
+	ReadmeTemplate string `json:"readme_template"`
+	// Group the posts in README by year and month
+	ReadmeGroupByDate bool `json:"readme_group_by_date"`
+	// When grouping by date, only the most recent years are expanded, the
+	// older ones are collapsed with <details>. 0 expands all years
+	ReadmeExpandedYears   int    `json:"readme_expanded_years"`
+	MataroaUrl            string `json:"mataroa_url"`
+	MataroaBlogUrl        string `json:"mataroa_blog_url"`
+	WriteFreelyUrl        string `json:"writefreely_url"`
+	WriteFreelyCollection string `json:"writefreely_collection"`
+	GeminiUrl             string `json:"gemini_url"`
+
+	// Loaded from Timezone
+	tz *time.Location
+}
+
+// config is loaded in main, before doing anything else
+var config blogConfig
+
+func defaultConfig() blogConfig {
+	return blogConfig{
+		Title:          "kokada's blog",
+		Description:    "# dd if=/dev/urandom of=/dev/brain0",
+		Author:         "Thiago Kenji Okada",
+		Email:          "thiagokokada@gmail.com",
+		BaseUrl:        "https://github.com/thiagokokada/blog",
+		BlogUrl:        "https://kokada.capivaras.dev/",
+		PostsRoot:      "posts",
+		ChromaStyle:    "monokai",
+		Timezone:       "UTC",
+		MataroaUrl:     "https://capivaras.dev",
+		MataroaBlogUrl: "https://kokada.dev/blog/",
+		GeminiUrl:      "gemini://kokada.dev/",
+	}
+}
+
+// envOverrides maps each environment variable to the config field it
+// overrides
+func (c *blogConfig) envOverrides() map[string]any {
+	return map[string]any{
+		"BLOG_TITLE":                 &c.Title,
+		"BLOG_DESCRIPTION":           &c.Description,
+		"BLOG_AUTHOR":                &c.Author,
+		"BLOG_EMAIL":                 &c.Email,
+		"BLOG_BASE_URL":              &c.BaseUrl,
+		"BLOG_MAIN_URL":              &c.MainUrl,
+		"BLOG_RAW_URL":               &c.RawUrl,
+		"BLOG_URL":                   &c.BlogUrl,
+		"BLOG_FEED_URL":              &c.FeedUrl,
+		"BLOG_POSTS_ROOT":            &c.PostsRoot,
+		"BLOG_CHROMA_STYLE":          &c.ChromaStyle,
+		"BLOG_TIMEZONE":              &c.Timezone,
+		"BLOG_README_TEMPLATE":       &c.ReadmeTemplate,
+		"BLOG_README_GROUP_BY_DATE":  &c.ReadmeGroupByDate,
+		"BLOG_README_EXPANDED_YEARS": &c.ReadmeExpandedYears,
+		"MATAROA_URL":                &c.MataroaUrl,
+		"MATAROA_BLOG_URL":           &c.MataroaBlogUrl,
+		"WRITEFREELY_URL":            &c.WriteFreelyUrl,
+		"WRITEFREELY_COLLECTION":     &c.WriteFreelyCollection,
+		"GEMINI_URL":                 &c.GeminiUrl,
+	}
+}
+
+// loadConfig loads the config from file on top of the defaults, and
+// afterwards applies the overrides from the environment. The file is optional
+// unless required is true
+func loadConfig(file string, required bool) (blogConfig, error) {
+	c := defaultConfig()
+
+	raw, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		raw = nil
+	} else if err != nil {
+		return c, fmt.Errorf("could not read config: %w", err)
+	}
+	if raw != nil {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		// Catch typos in the config
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+		if err != nil {
+			return c, fmt.Errorf("config file: %s, JSON unmarshal error: %w", file, err)
+		}
+	}
+
+	for env, field := range c.envOverrides() {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		switch field := field.(type) {
+		case *string:
+			*field = v
+		case *bool:
+			*field, err = strconv.ParseBool(v)
+		case *int:
+			*field, err = strconv.Atoi(v)
+		}
+		if err != nil {
+			return c, fmt.Errorf("invalid value for environment variable: %s, error: %w", env, err)
+		}
+	}
+
+	c.BaseUrl = strings.TrimSuffix(c.BaseUrl, "/")
+	if c.MainUrl == "" {
+		c.MainUrl = c.BaseUrl + "/blob/main/"
+	}
+	if c.RawUrl == "" {
+		c.RawUrl = c.BaseUrl + "/raw/main/"
+	}
+	if c.FeedUrl == "" {
+		c.FeedUrl = c.RawUrl + rssFile
+	}
+	c.tz, err = time.LoadLocation(c.Timezone)
+	if err != nil {
+		return c, fmt.Errorf("invalid timezone in config: %s, error: %w", c.Timezone, err)
+	}
+	return c, c.validateUrls()
+}
+
+// location returns the timezone for dates without one, UTC if the config was
+// not loaded
+func (c *blogConfig) location() *time.Location {
+	if c.tz == nil {
+		return time.UTC
+	}
+	return c.tz
+}
+
+// validateUrls checks the URLs early, so we don't fail halfway through
+// generating the files
+func (c *blogConfig) validateUrls() error {
+	urls := map[string]string{
+		"base_url":         c.BaseUrl,
+		"main_url":         c.MainUrl,
+		"raw_url":          c.RawUrl,
+		"blog_url":         c.BlogUrl,
+		"feed_url":         c.FeedUrl,
+		"mataroa_url":      c.MataroaUrl,
+		"mataroa_blog_url": c.MataroaBlogUrl,
+		"writefreely_url":  c.WriteFreelyUrl,
+		"gemini_url":       c.GeminiUrl,
+	}
+	for _, key := range slices.Sorted(maps.Keys(urls)) {
+		_, err := url.Parse(urls[key])
+		if err != nil {
+			return fmt.Errorf("invalid URL in config: %s, error: %w", key, err)
+		}
+	}
+	return nil
+}
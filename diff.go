@@ -0,0 +1,141 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines returns the operations to transform a into b, based on the
+// longest common subsequence between them
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the LCS between a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// splitLines splits s in lines, where an empty s has no lines (instead of a
+// single empty one)
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// hunkRange formats the range of count lines after the line before, with
+// lines starting at 1. An empty range refers to the line before it, e.g.: 0,0
+// when adding lines to an empty file
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// unifiedDiff returns the difference between a and b in unified format, or
+// an empty string if they're equal
+func unifiedDiff(aName, bName, a, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	// Line numbers in a and b for each operation
+	aLine, bLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Grow the hunk until the gap between changes is bigger than
+		// the context of both sides
+		start, last := max(i-diffContext, 0), i
+		for k := i; k < len(ops) && k-last <= 2*diffContext; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		end := min(last+1+diffContext, len(ops))
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+		}
+		fmt.Fprintf(
+			&sb,
+			"@@ -%s +%s @@\n",
+			hunkRange(aLine[start], aLine[end]-aLine[start]),
+			hunkRange(bLine[start], bLine[end]-bLine[start]),
+		)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", op.kind, op.line)
+		}
+		i = end
+	}
+
+	return sb.String()
+}
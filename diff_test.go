@@ -0,0 +1,99 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int) string {
+		var l []string
+		for i := range n {
+			l = append(l, string(rune('a'+i)))
+		}
+		return strings.Join(l, "\n")
+	}
+
+	for _, tt := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "x\ny",
+			b:    "x\ny",
+			want: "",
+		},
+		{
+			name: "both empty",
+		},
+		{
+			name: "created",
+			b:    "x\ny",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "deleted",
+			a:    "x\ny",
+			want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n",
+		},
+		{
+			name: "changed line",
+			a:    "x\ny\nz",
+			b:    "x\nY\nz",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n x\n-y\n+Y\n z\n",
+		},
+		{
+			name: "added in the middle",
+			a:    "x\nz",
+			b:    "x\ny\nz",
+			want: "--- a\n+++ b\n@@ -1,2 +1,3 @@\n x\n+y\n z\n",
+		},
+		{
+			// Only diffContext lines around the change
+			name: "context",
+			a:    lines(10),
+			b:    strings.Replace(lines(10), "e", "E", 1),
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n",
+		},
+		{
+			// Changes far apart are in separate hunks
+			name: "separate hunks",
+			a:    lines(20),
+			b:    strings.NewReplacer("b", "B", "s", "S").Replace(lines(20)),
+			want: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+				"@@ -16,5 +16,5 @@\n p\n q\n r\n-s\n+S\n t\n",
+		},
+		{
+			// Changes close enough are merged in a single hunk
+			name: "merged hunks",
+			a:    lines(12),
+			b:    strings.NewReplacer("b", "B", "h", "H").Replace(lines(12)),
+			want: "--- a\n+++ b\n" +
+				"@@ -1,11 +1,11 @@\n a\n-b\n+B\n c\n d\n e\n f\n g\n-h\n+H\n i\n j\n k\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("a", "b", tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Exit codes, so scripts (e.g.: CI) can tell what kind of failure happened
+const (
+	exitFailure      = 1
+	exitUsage        = 2
+	exitInvalidPosts = 3
+	exitIssues       = 4
+	exitRemote       = 5
+	exitInterrupted  = 130
+)
+
+// errAborted is returned when the user doesn't confirm an action (e.g.:
+// -prune), so it is handled like an interruption
+var errAborted = errors.New("aborted by user")
+
+// exitError sets the exit code for err
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err with the exit code, or nil if err is nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// postError is an error in a post, with the position where it happened
+type postError struct {
+	file path
+	line int
+	err  error
+}
+
+func (e *postError) Error() string {
+	if e.line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.file, e.line, e.err)
+	}
+	return fmt.Sprintf("%s: %v", e.file, e.err)
+}
+
+func (e *postError) Unwrap() error {
+	return e.err
+}
+
+// lineError is an error in a specific line of a post. The file is added
+// later, by wrapping it in a postError
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return e.err.Error()
+}
+
+func (e *lineError) Unwrap() error {
+	return e.err
+}
+
+// newPostError returns err as an error in file, using the line from err if
+// it has one
+func newPostError(file path, err error) *postError {
+	pe := &postError{file: file, err: err}
+	var le *lineError
+	if errors.As(err, &le) {
+		pe.line = le.line
+	}
+	return pe
+}
+
+// onlyPostErrors returns true if all errors in err are about invalid posts,
+// e.g.: it is safe to ignore them and use the posts that could be loaded
+func onlyPostErrors(err error) bool {
+	for _, err := range flattenErrors(err) {
+		var pe *postError
+		if !errors.As(err, &pe) {
+			return false
+		}
+	}
+	return true
+}
+
+// exitCode returns the exit code for err, based on the failure class
+func exitCode(err error) int {
+	var ee *exitError
+	var pe *postError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, errAborted):
+		return exitInterrupted
+	// Invalid posts take precedence, e.g.: a post failing to render while
+	// publishing is not a remote error
+	case errors.As(err, &pe):
+		return exitInvalidPosts
+	case errors.As(err, &ee):
+		return ee.code
+	default:
+		return exitFailure
+	}
+}
+
+// flattenErrors returns each error joined in err (e.g.: with errors.Join), so
+// they can be reported one per line
+func flattenErrors(err error) []error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case interface{ Unwrap() []error }:
+		var errs []error
+		for _, err := range e.Unwrap() {
+			errs = append(errs, flattenErrors(err)...)
+		}
+		return errs
+	case *exitError:
+		return flattenErrors(e.err)
+	default:
+		return []error{err}
+	}
+}
+
+// reportError prints err in w, returning the exit code
+func reportError(w io.Writer, err error) int {
+	errs := flattenErrors(err)
+	if len(errs) > 1 {
+		fmt.Fprintf(w, "[ERROR]: found %d errors:\n", len(errs))
+	}
+	for _, err := range errs {
+		fmt.Fprintf(w, "[ERROR]: %v\n", err)
+	}
+	return exitCode(err)
+}
@@ -0,0 +1,255 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	yamlFrontMatterDelim = "---"
+	tomlFrontMatterDelim = "+++"
+)
+
+// frontMatter is the optional metadata block at the start of a post. Only a
+// small subset of YAML/TOML is supported: one "key: value" (YAML) or
+// "key = value" (TOML) per line, with strings, booleans and lists of strings
+// as values
+type frontMatter struct {
+	title   string
+	date    time.Time
+	updated time.Time
+	tags    []string
+	summary string
+	draft   bool
+	slug    string
+	aliases []string
+	lang    string
+}
+
+// extractFrontMatter splits the front matter from the rest of the file. If
+// there is no front matter it returns a zero frontMatter and raw unchanged
+func extractFrontMatter(raw []byte) (fm frontMatter, rest []byte, err error) {
+	var delim, sep string
+	switch {
+	case hasDelimLine(raw, yamlFrontMatterDelim):
+		delim, sep = yamlFrontMatterDelim, ":"
+	case hasDelimLine(raw, tomlFrontMatterDelim):
+		delim, sep = tomlFrontMatterDelim, "="
+	default:
+		return fm, raw, nil
+	}
+
+	lines := strings.Split(string(raw), "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == delim {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return fm, raw, &lineError{1, fmt.Errorf("missing closing '%s' in front matter", delim)}
+	}
+
+	// The front matter starts after the delimiter, in the second line
+	err = fm.parse(lines[1:end], sep, 2)
+	if err != nil {
+		return fm, raw, err
+	}
+
+	return fm, []byte(strings.Join(lines[end+1:], "\n")), nil
+}
+
+func hasDelimLine(raw []byte, delim string) bool {
+	line, _, _ := bytes.Cut(raw, []byte("\n"))
+	return string(bytes.TrimRight(line, " \t\r")) == delim
+}
+
+// parse parses the front matter lines, starting at firstLine in the file (used
+// in errors)
+func (fm *frontMatter) parse(lines []string, sep string, firstLine int) error {
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		// Ignore empty lines and comments
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return &lineError{firstLine + i, fmt.Errorf("invalid front matter line: %q", line)}
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		// YAML block lists, e.g.:
+		// tags:
+		//   - nix
+		//   - go
+		keyLine := firstLine + i
+		var items []string
+		if value == "" && sep == ":" {
+			for i+1 < len(lines) {
+				item, ok := strings.CutPrefix(strings.TrimSpace(lines[i+1]), "- ")
+				if !ok {
+					break
+				}
+				s, err := parseFrontMatterString(item)
+				if err != nil {
+					return &lineError{firstLine + i + 1, fmt.Errorf("invalid list item for key %s: %w", key, err)}
+				}
+				items = append(items, s)
+				i++
+			}
+		}
+
+		err := fm.set(key, value, items)
+		if err != nil {
+			return &lineError{keyLine, fmt.Errorf("invalid value for key %s: %w", key, err)}
+		}
+	}
+	return nil
+}
+
+func (fm *frontMatter) set(key, value string, items []string) (err error) {
+	switch key {
+	case "title":
+		fm.title, err = parseFrontMatterString(value)
+	case "date":
+		var s string
+		s, err = parseFrontMatterString(value)
+		if err == nil {
+			fm.date, err = parsePostDate(s)
+		}
+	case "updated", "lastmod":
+		var s string
+		s, err = parseFrontMatterString(value)
+		if err == nil {
+			fm.updated, err = parsePostDate(s)
+		}
+	case "tags":
+		if items != nil {
+			fm.tags = items
+		} else {
+			fm.tags, err = parseFrontMatterList(value)
+		}
+	case "aliases":
+		if items != nil {
+			fm.aliases = items
+		} else {
+			fm.aliases, err = parseFrontMatterList(value)
+		}
+	case "summary", "description":
+		fm.summary, err = parseFrontMatterString(value)
+	case "draft":
+		fm.draft, err = strconv.ParseBool(value)
+	case "slug":
+		fm.slug, err = parseFrontMatterString(value)
+	case "lang", "language":
+		fm.lang, err = parseFrontMatterString(value)
+	default:
+		err = fmt.Errorf("unknown key")
+	}
+	return err
+}
+
+// postDateFormats are the formats accepted in front matter dates. A time
+// allows scheduling the post (e.g.: to be published in the afternoon), and
+// without an offset it is in the config timezone
+var postDateFormats = []string{
+	time.DateOnly,
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+}
+
+func parsePostDate(s string) (time.Time, error) {
+	for _, format := range postDateFormats {
+		t, err := time.ParseInLocation(format, s, config.location())
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s, valid formats: %v", s, postDateFormats)
+}
+
+func parseFrontMatterString(s string) (string, error) {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			return strconv.Unquote(s)
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+		}
+	}
+	// Unquoted strings may contain quotes (e.g.: "don't"), but not start
+	// with one
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return "", fmt.Errorf("unbalanced quotes: %s", s)
+	}
+	return s, nil
+}
+
+// splitFrontMatterList splits the items of a list on the commas outside of
+// quotes, e.g.: "a, b", c => ["\"a, b\"", " c"]. Like in
+// parseFrontMatterString, only a quote at the start of an item is considered
+// a quoted string
+func splitFrontMatterList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\', quote == '\'' && c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			// Skip the escaped character (e.g.: \" or '')
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\'') && strings.TrimSpace(s[start:i]) == "":
+			quote = c
+		case quote == 0 && c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+func parseFrontMatterList(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("expected list, got: %s", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return nil, nil
+	}
+
+	var list []string
+	for _, item := range splitFrontMatterList(s) {
+		item, err := parseFrontMatterString(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
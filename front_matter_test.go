@@ -0,0 +1,220 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractFrontMatter(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.ParseInLocation(time.DateOnly, s, config.location())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	for _, tt := range []struct {
+		name     string
+		raw      string
+		want     frontMatter
+		wantRest string
+		// Substring of the error, and the line it should be reported
+		wantErr  string
+		wantLine int
+	}{
+		{
+			name:     "no front matter",
+			raw:      "# Title\n\nBody\n",
+			wantRest: "# Title\n\nBody\n",
+		},
+		{
+			name: "YAML",
+			raw: "---\ntitle: Hello, world\ndate: 2024-08-01\nupdated: 2024-08-02\ntags: [nix, go]\n" +
+				"summary: Short\ndraft: true\nslug: hello\naliases: [old]\nlang: pt\n---\n# Hello\n",
+			want: frontMatter{
+				title:   "Hello, world",
+				date:    date("2024-08-01"),
+				updated: date("2024-08-02"),
+				tags:    []string{"nix", "go"},
+				summary: "Short",
+				draft:   true,
+				slug:    "hello",
+				aliases: []string{"old"},
+				lang:    "pt",
+			},
+			wantRest: "# Hello\n",
+		},
+		{
+			name:     "TOML",
+			raw:      "+++\ntitle = \"Hello\"\ntags = [\"nix\"]\ndraft = false\n+++\n# Hello\n",
+			want:     frontMatter{title: "Hello", tags: []string{"nix"}},
+			wantRest: "# Hello\n",
+		},
+		{
+			name:     "CRLF",
+			raw:      "---\r\ntitle: Hello\r\ntags: [a, b]\r\n---\r\n# Hello\r\n",
+			want:     frontMatter{title: "Hello", tags: []string{"a", "b"}},
+			wantRest: "# Hello\r\n",
+		},
+		{
+			name:     "comments and empty lines",
+			raw:      "---\n# A comment\n\ntitle: Hello\n---\n# Hello\n",
+			want:     frontMatter{title: "Hello"},
+			wantRest: "# Hello\n",
+		},
+		{
+			name:     "quotes",
+			raw:      "---\ntitle: \"Say \\\"hi\\\"\"\nsummary: 'It''s here'\nslug: don't\n---\n",
+			want:     frontMatter{title: `Say "hi"`, summary: "It's here", slug: "don't"},
+			wantRest: "",
+		},
+		{
+			name:     "quoted list items with commas",
+			raw:      "---\ntags: [\"a, b\", 'c, ''d''', e]\n---\n",
+			want:     frontMatter{tags: []string{"a, b", "c, 'd'", "e"}},
+			wantRest: "",
+		},
+		{
+			name:     "empty inline list",
+			raw:      "---\ntags: []\n---\n",
+			want:     frontMatter{},
+			wantRest: "",
+		},
+		{
+			name:     "YAML block list",
+			raw:      "---\ntags:\n  - nix\n  - \"go\"\ntitle: Hello\n---\n",
+			want:     frontMatter{tags: []string{"nix", "go"}, title: "Hello"},
+			wantRest: "",
+		},
+		{
+			name:     "unbalanced double quotes",
+			raw:      "---\ntitle: Hello\nsummary: \"Short\n---\n",
+			wantErr:  "invalid value for key summary: unbalanced quotes",
+			wantLine: 3,
+		},
+		{
+			name:     "unbalanced single quotes in list",
+			raw:      "---\ntags: ['nix, go]\n---\n",
+			wantErr:  "invalid value for key tags: unbalanced quotes",
+			wantLine: 2,
+		},
+		{
+			name:     "invalid YAML block list item",
+			raw:      "---\ntags:\n  - nix\n  - 'go\n---\n",
+			wantErr:  "invalid list item for key tags: unbalanced quotes",
+			wantLine: 4,
+		},
+		{
+			name:     "missing closing delimiter",
+			raw:      "---\ntitle: Hello\n# Hello\n",
+			wantErr:  "missing closing '---' in front matter",
+			wantLine: 1,
+		},
+		{
+			name:     "unknown key",
+			raw:      "+++\ntitle = \"Hello\"\n\nauthor = \"Me\"\n+++\n",
+			wantErr:  "invalid value for key author: unknown key",
+			wantLine: 4,
+		},
+		{
+			name:     "invalid line",
+			raw:      "---\ntitle Hello\n---\n",
+			wantErr:  "invalid front matter line",
+			wantLine: 2,
+		},
+		{
+			name:     "invalid date",
+			raw:      "---\ndate: yesterday\n---\n",
+			wantErr:  "invalid value for key date: invalid date: yesterday",
+			wantLine: 2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, rest, err := extractFrontMatter([]byte(tt.raw))
+			if tt.wantErr != "" {
+				var le *lineError
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error: %v, want: %s", err, tt.wantErr)
+				}
+				if !errors.As(err, &le) || le.line != tt.wantLine {
+					t.Errorf("got error in line: %v, want: %d", le, tt.wantLine)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fm.date.Equal(tt.want.date) || !fm.updated.Equal(tt.want.updated) {
+				t.Errorf("got date: %v, updated: %v, want date: %v, updated: %v", fm.date, fm.updated, tt.want.date, tt.want.updated)
+			}
+			fm.date, fm.updated, tt.want.date, tt.want.updated = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+			if !reflect.DeepEqual(fm, tt.want) {
+				t.Errorf("got front matter: %+v, want: %+v", fm, tt.want)
+			}
+			if string(rest) != tt.wantRest {
+				t.Errorf("got rest: %q, want: %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestParsePostDate(t *testing.T) {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tz := config.tz
+	config.tz = loc
+	t.Cleanup(func() { config.tz = tz })
+
+	for _, tt := range []struct {
+		s    string
+		want time.Time
+	}{
+		// Without an offset it is in the config timezone
+		{"2024-08-01", time.Date(2024, 8, 1, 0, 0, 0, 0, loc)},
+		{"2024-08-01T15:04", time.Date(2024, 8, 1, 15, 4, 0, 0, loc)},
+		{"2024-08-01 15:04", time.Date(2024, 8, 1, 15, 4, 0, 0, loc)},
+		{"2024-08-01 15:04:05", time.Date(2024, 8, 1, 15, 4, 5, 0, loc)},
+		{"2024-08-01T15:04:05-03:00", time.Date(2024, 8, 1, 15, 4, 5, 0, loc)},
+		{"2024-08-01T15:04:05Z", time.Date(2024, 8, 1, 15, 4, 5, 0, time.UTC)},
+		{"2024-08-01T15:04:05+09:00", time.Date(2024, 8, 1, 6, 4, 5, 0, time.UTC)},
+	} {
+		got, err := parsePostDate(tt.s)
+		if err != nil {
+			t.Errorf("%s: got error: %v", tt.s, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: got: %v, want: %v", tt.s, got, tt.want)
+		}
+	}
+
+	// Every format should be covered above
+	for _, format := range postDateFormats {
+		if _, err := parsePostDate(time.Date(2024, 8, 1, 15, 4, 5, 0, loc).Format(format)); err != nil {
+			t.Errorf("format: %s, got error: %v", format, err)
+		}
+	}
+	if _, err := parsePostDate("2024-08-01 15"); err == nil {
+		t.Error("got no error for an invalid date")
+	}
+}
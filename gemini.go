@@ -0,0 +1,408 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/feeds"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+const geminiIndexFile = "index.gmi"
+
+// gemtextLink is a link found inside a block, that will be written as a link
+// line (=>) after it, since gemtext has no inline links
+type gemtextLink struct {
+	url   string
+	label string
+}
+
+func (l gemtextLink) String() string {
+	if l.label == "" || l.label == l.url {
+		return "=> " + l.url
+	}
+	return fmt.Sprintf("=> %s %s", l.url, l.label)
+}
+
+// gemtext converts the goldmark AST to gemtext (text/gemini)
+type gemtext struct {
+	source []byte
+}
+
+// inline returns the text of an inline node and the links inside it
+func (g *gemtext) inline(n ast.Node) (string, []gemtextLink) {
+	var sb strings.Builder
+	var links []gemtextLink
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Segment.Value(g.source))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(c.Value)
+		case *ast.CodeSpan:
+			code, _ := g.inline(c)
+			sb.WriteString("`" + code + "`")
+		case *ast.Link:
+			label, inner := g.inline(c)
+			sb.WriteString(label)
+			// An image inside a link (e.g.: a thumbnail) is only
+			// useful in the link itself, since Gemini clients don't
+			// show images inline
+			if _, ok := c.FirstChild().(*ast.Image); !ok || c.ChildCount() != 1 {
+				links = append(links, inner...)
+			}
+			links = append(links, gemtextLink{string(c.Destination), label})
+		case *ast.Image:
+			alt, _ := g.inline(c)
+			sb.WriteString(alt)
+			links = append(links, gemtextLink{string(c.Destination), alt})
+		case *ast.AutoLink:
+			u := string(c.URL(g.source))
+			sb.WriteString(u)
+			links = append(links, gemtextLink{u, u})
+		case *ast.RawHTML:
+			// Not supported in gemtext
+		case *extast.TaskCheckBox:
+			if c.IsChecked {
+				sb.WriteString("[x] ")
+			} else {
+				sb.WriteString("[ ] ")
+			}
+		default:
+			s, inner := g.inline(c)
+			sb.WriteString(s)
+			links = append(links, inner...)
+		}
+	}
+	return sb.String(), links
+}
+
+// lines returns the raw lines of a block, e.g.: code blocks
+func (g *gemtext) lines(n ast.Node) string {
+	var sb strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		sb.Write(line.Value(g.source))
+	}
+	return sb.String()
+}
+
+func preformatted(alt, contents string) string {
+	return "```" + alt + "\n" + strings.TrimRight(contents, "\n") + "\n```"
+}
+
+// table renders a table as preformatted text, since gemtext has no tables
+func (g *gemtext) table(n *extast.Table) (string, []gemtextLink) {
+	var rows [][]string
+	var links []gemtextLink
+	var widths []int
+	for r := n.FirstChild(); r != nil; r = r.NextSibling() {
+		var row []string
+		for i, c := 0, r.FirstChild(); c != nil; i, c = i+1, c.NextSibling() {
+			cell, inner := g.inline(c)
+			row = append(row, cell)
+			links = append(links, inner...)
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+		rows = append(rows, row)
+	}
+
+	var sb strings.Builder
+	for i, row := range rows {
+		var cells []string
+		for j, cell := range row {
+			cells = append(cells, cell+strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+		}
+		sb.WriteString(strings.TrimRight(strings.Join(cells, " | "), " ") + "\n")
+		// Separate the header from the rows
+		if i == 0 {
+			var seps []string
+			for _, w := range widths {
+				seps = append(seps, strings.Repeat("-", w))
+			}
+			sb.WriteString(strings.Join(seps, "-|-") + "\n")
+		}
+	}
+	return preformatted("table", sb.String()), links
+}
+
+// block returns the gemtext of a block node, and the links that should be
+// written after it
+func (g *gemtext) block(n ast.Node) (string, []gemtextLink) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		// gemtext only supports up to 3 levels of headings
+		heading, links := g.inline(n)
+		return strings.Repeat("#", min(n.Level, 3)) + " " + heading, links
+	case *ast.Paragraph, *ast.TextBlock:
+		return g.inline(n)
+	case *ast.FencedCodeBlock:
+		return preformatted(string(n.Language(g.source)), g.lines(n)), nil
+	case *ast.CodeBlock:
+		return preformatted("", g.lines(n)), nil
+	case *ast.HTMLBlock:
+		return preformatted("html", g.lines(n)), nil
+	case *ast.ThematicBreak:
+		return "---", nil
+	case *extast.Table:
+		return g.table(n)
+	case *ast.Blockquote:
+		var lines []string
+		var links []gemtextLink
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			s, inner := g.block(c)
+			if len(lines) > 0 {
+				lines = append(lines, ">")
+			}
+			for _, line := range strings.Split(s, "\n") {
+				lines = append(lines, "> "+line)
+			}
+			links = append(links, inner...)
+		}
+		return strings.Join(lines, "\n"), links
+	case *ast.List:
+		var lines []string
+		var links []gemtextLink
+		for i, item := 0, n.FirstChild(); item != nil; i, item = i+1, item.NextSibling() {
+			// gemtext has no ordered or nested lists, so we keep the
+			// numbers as text and indent the nested ones
+			prefix := "* "
+			if n.IsOrdered() {
+				prefix = strconv.Itoa(n.Start+i) + ". "
+			}
+			for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+				s, inner := g.block(c)
+				if _, ok := c.(*ast.List); ok {
+					lines = append(lines, "  "+strings.ReplaceAll(s, "\n", "\n  "))
+				} else {
+					lines = append(lines, prefix+s)
+					prefix = ""
+				}
+				links = append(links, inner...)
+			}
+		}
+		return strings.Join(lines, "\n"), links
+	default:
+		return g.inline(n)
+	}
+}
+
+// render converts the post contents to gemtext, writing the links found in
+// each top-level block right after it
+func (g *gemtext) render(doc ast.Node) string {
+	var blocks []string
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		s, links := g.block(n)
+
+		// Avoid repeating the same link, e.g.: an image inside a link
+		// to itself
+		seen := map[string]bool{}
+		var linkLines []string
+		for _, l := range links {
+			if seen[l.url] {
+				continue
+			}
+			seen[l.url] = true
+			linkLines = append(linkLines, l.String())
+		}
+		// A paragraph with only one link (e.g.: an image) doesn't need
+		// to repeat its label
+		if len(links) > 0 && strings.TrimSpace(s) == links[0].label && len(seen) == 1 {
+			s = ""
+		}
+
+		block := strings.Join(append([]string{s}, linkLines...), "\n")
+		blocks = append(blocks, strings.TrimLeft(block, "\n"))
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// capsule renders posts as a Gemini capsule (gemlog)
+type capsule struct {
+	posts posts
+	md    goldmark.Markdown
+}
+
+func newCapsule(ps posts) *capsule {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			// Gemini supports absolute paths, so we can keep the
+			// same structure as the site
+			NewLinkRewriter(siteBaseUrl, siteBaseUrl, ps),
+			extension.GFM,
+		),
+	)
+	return &capsule{posts: ps, md: md}
+}
+
+func (c *capsule) postUrl(p post) string {
+	return siteBaseUrl + p.slug + "/"
+}
+
+// renderIndex renders the gemlog index, following the Gemini subscription
+// format so it can be subscribed by Gemini clients
+// https://geminiprotocol.net/docs/companion/subscription.gmi
+func (c *capsule) renderIndex(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("# " + config.Title + "\n\n")
+	sb.WriteString("## " + config.Description + "\n\n")
+	sb.WriteString(gemtextLink{atomFile, "Atom feed"}.String() + "\n\n")
+	for _, post := range c.posts.AllFromBack() {
+		sb.WriteString(gemtextLink{
+			c.postUrl(post),
+			fmt.Sprintf("%s %s", post.date.Format(time.DateOnly), post.title),
+		}.String() + "\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (c *capsule) renderPost(w io.Writer, p post) error {
+	g := gemtext{source: p.contents}
+	doc := c.md.Parser().Parse(text.NewReader(p.contents), parser.WithContext(postContext(p)))
+
+	var sb strings.Builder
+	sb.WriteString("# " + p.title + "\n\n")
+	sb.WriteString(p.date.Format(time.DateOnly))
+	if len(p.tags) > 0 {
+		sb.WriteString(" #" + strings.Join(p.tags, " #"))
+	}
+	sb.WriteString("\n\n" + g.render(doc) + "\n")
+	sb.WriteString(gemtextLink{siteBaseUrl, "Back to index"}.String() + "\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// renderRedirect renders a stub page pointing from an alias to the post,
+// since redirects depend on the Gemini server
+func (c *capsule) renderRedirect(w io.Writer, p post) error {
+	_, err := fmt.Fprintf(
+		w,
+		"# %s\n\nThis post was moved to:\n\n%s\n",
+		p.title,
+		gemtextLink{c.postUrl(p), p.title},
+	)
+	return err
+}
+
+// renderAtom renders an Atom feed pointing to the capsule
+func (c *capsule) renderAtom(w io.Writer) error {
+	feed := &feeds.Feed{
+		Title:       config.Title,
+		Description: config.Description,
+		Link:        &feeds.Link{Href: config.GeminiUrl},
+		Author:      &feeds.Author{Name: config.Author, Email: config.Email},
+	}
+	for _, post := range c.posts.AllFromBack() {
+		link, err := url.JoinPath(config.GeminiUrl, post.slug, "/")
+		if err != nil {
+			return err
+		}
+		updated := post.updated
+		if updated.IsZero() {
+			updated = post.date
+		}
+		if updated.After(feed.Updated) {
+			feed.Updated = updated
+		}
+		feed.Items = append(feed.Items, &feeds.Item{
+			Title:       post.title,
+			Link:        &feeds.Link{Href: link},
+			Created:     post.date,
+			Updated:     updated,
+			Id:          link,
+			Description: post.summary,
+		})
+	}
+
+	self, err := url.JoinPath(config.GeminiUrl, atomFile)
+	if err != nil {
+		return err
+	}
+	atom := (&feeds.Atom{Feed: feed}).AtomFeed()
+	links := []feeds.AtomLink{
+		{Href: atom.Link.Href, Rel: "alternate"},
+		{Href: self, Rel: "self"},
+	}
+	atom.Link = nil
+	return feeds.WriteXML(&atomFeed{Links: links, AtomFeed: atom}, w)
+}
+
+// genGemini generates a Gemini capsule in outDir, with the same structure as
+// the site generated by genSite
+func genGemini(ps posts, root, outDir string) error {
+	c := newCapsule(ps)
+
+	err := writeSiteFile(filepath.Join(outDir, geminiIndexFile), c.renderIndex)
+	if err != nil {
+		return err
+	}
+	err = writeSiteFile(filepath.Join(outDir, atomFile), c.renderAtom)
+	if err != nil {
+		return err
+	}
+
+	for path, post := range ps.AllFromFront() {
+		err = writeSiteFile(
+			filepath.Join(outDir, post.slug, geminiIndexFile),
+			func(w io.Writer) error { return c.renderPost(w, post) },
+		)
+		if err != nil {
+			return fmt.Errorf("something went wrong with file: %s, error: %w", path, err)
+		}
+
+		for _, alias := range post.aliases {
+			err = writeSiteFile(
+				filepath.Join(outDir, alias, geminiIndexFile),
+				func(w io.Writer) error { return c.renderRedirect(w, post) },
+			)
+			if err != nil {
+				return fmt.Errorf("something went wrong with file: %s, error: %w", path, err)
+			}
+		}
+	}
+
+	err = copyImages(root, outDir)
+	if err != nil {
+		return err
+	}
+	err = copyImages(imagesDir, outDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO]: generated %d posts in: %s\n", ps.Len(), outDir)
+	return nil
+}
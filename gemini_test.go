@@ -0,0 +1,88 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenGeminiAliasRedirect(t *testing.T) {
+	setupPosts(t, aliasPosts)
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	err = genGemini(ps, config.PostsRoot, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(outDir, "old-title", geminiIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "=> /new-title/ New title"
+	if !strings.Contains(string(raw), want) {
+		t.Errorf("redirect stub doesn't contain: %s, got:\n%s", want, raw)
+	}
+}
+
+func TestGenGeminiLinkedImage(t *testing.T) {
+	setupPosts(t, map[string]string{
+		"2024-01-01/01-photos.md": "# Photos\n\n[![Big](/posts/2024-01-01/big.jpg)](/posts/2024-01-01/big.jpg)\n",
+		"2024-01-01/big.jpg":      "original",
+	})
+	for _, file := range []string{
+		optimizedImagePath("posts/2024-01-01/big.jpg"),
+		thumbnailImagePath("posts/2024-01-01/big.jpg"),
+	} {
+		err := os.MkdirAll(filepath.Dir(file), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(file, []byte("optimized"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	err = genGemini(ps, config.PostsRoot, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(outDir, "photos", geminiIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the full size image, since the thumbnail is not shown inline
+	want := "=> /optimized/posts/2024-01-01/big.jpg Big"
+	if !strings.Contains(string(raw), want) {
+		t.Errorf("post doesn't contain: %s, got:\n%s", want, raw)
+	}
+	if strings.Contains(string(raw), "thumb") {
+		t.Errorf("post contains the thumbnail, got:\n%s", raw)
+	}
+}
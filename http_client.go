@@ -0,0 +1,138 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	httpTimeout    = 30 * time.Second
+	httpMaxRetries = 5
+	httpBaseDelay  = time.Second
+	httpMaxDelay   = time.Minute
+)
+
+// retryClient is a HTTP client that retries requests on transient failures
+// (network errors, 429 and 5xx status codes) with exponential backoff
+type retryClient struct {
+	client     *http.Client
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func newRetryClient() *retryClient {
+	return &retryClient{
+		client:     &http.Client{Timeout: httpTimeout},
+		maxRetries: httpMaxRetries,
+		baseDelay:  httpBaseDelay,
+		maxDelay:   httpMaxDelay,
+	}
+}
+
+// shouldRetry returns true if the request can be safely retried. Since
+// retrying a POST may create duplicates, we only retry those when the server
+// explicitly said it didn't process the request
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if method == http.MethodPost {
+		return err == nil &&
+			(resp.StatusCode == http.StatusTooManyRequests ||
+				resp.StatusCode == http.StatusServiceUnavailable)
+	}
+	return err != nil ||
+		resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+}
+
+// retryDelay returns how long to wait before the next attempt, honoring the
+// Retry-After header if the server sent one
+func (c *retryClient) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if after := resp.Header.Get("Retry-After"); after != "" {
+			if secs, err := strconv.Atoi(after); err == nil {
+				return min(time.Duration(secs)*time.Second, c.maxDelay)
+			}
+			if t, err := http.ParseTime(after); err == nil {
+				return min(max(time.Until(t), 0), c.maxDelay)
+			}
+		}
+	}
+	// Add some jitter to avoid retrying all requests at the same time
+	delay := c.baseDelay<<attempt + rand.N(c.baseDelay)
+	return min(delay, c.maxDelay)
+}
+
+// do sends the request and returns the response with its body already read,
+// retrying on transient failures until ctx is cancelled or we run out of
+// retries
+func (c *retryClient) do(
+	ctx context.Context,
+	method, url string,
+	header http.Header,
+	body []byte,
+) (resp *http.Response, respBody []byte, err error) {
+	for attempt := 0; ; attempt++ {
+		// The body is consumed on each attempt, so create a new one
+		var reqBuf io.Reader
+		if body != nil {
+			reqBuf = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBuf)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header = header.Clone()
+
+		resp, err = c.client.Do(req)
+		if err == nil {
+			respBody, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return resp, respBody, ctx.Err()
+		}
+		if !shouldRetry(method, resp, err) {
+			return resp, respBody, err
+		}
+		if attempt >= c.maxRetries {
+			if err == nil {
+				err = fmt.Errorf("giving up after %d retries, status code: %d", attempt, resp.StatusCode)
+			}
+			return resp, respBody, err
+		}
+
+		delay := c.retryDelay(resp, attempt)
+		if err != nil {
+			log.Printf("[RETRY] %s %s (attempt=%d, delay=%s): %v\n", method, url, attempt+1, delay, err)
+		} else {
+			log.Printf("[RETRY] %s %s (attempt=%d, delay=%s): code=%d\n", method, url, attempt+1, delay, resp.StatusCode)
+		}
+
+		select {
+		case <-ctx.Done():
+			return resp, respBody, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
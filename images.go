@@ -0,0 +1,380 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Optimized images are generated in this directory, keeping the same
+	// structure as the originals
+	imagesDir        = "optimized"
+	imageMaxWidth    = 1600
+	imageThumbWidth  = 400
+	imageJpegQuality = 80
+)
+
+// optimizedImagePath returns where the optimized version of the image in p
+// is, e.g.: posts/2024-07-30/a.jpg => optimized/posts/2024-07-30/a.jpg
+func optimizedImagePath(p string) string {
+	return filepath.Join(imagesDir, p)
+}
+
+// thumbnailImagePath returns where the thumbnail of the image in p is, e.g.:
+// posts/2024-07-30/a.jpg => optimized/posts/2024-07-30/a.thumb.jpg
+func thumbnailImagePath(p string) string {
+	ext := filepath.Ext(p)
+	return filepath.Join(imagesDir, strings.TrimSuffix(p, ext)+".thumb"+ext)
+}
+
+// imageVariant returns the path of the smallest version of the image in p
+// that was generated: the thumbnail (if thumb is true), the optimized image
+// or the original one. Each check is recorded in deps, since the output
+// changes once the images are generated
+func imageVariant(p string, thumb bool, deps *renderDeps) string {
+	if thumb && deps.exists(thumbnailImagePath(p)) {
+		return filepath.ToSlash(thumbnailImagePath(p))
+	}
+	if deps.exists(optimizedImagePath(p)) {
+		return filepath.ToSlash(optimizedImagePath(p))
+	}
+	return p
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, or 1 (normal) if it
+// can't find one. Since we strip the EXIF metadata, we need to rotate the
+// image ourselves, otherwise photos from phones will end up sideways
+func jpegOrientation(raw []byte) int {
+	if !bytes.HasPrefix(raw, []byte{0xff, 0xd8}) {
+		return 1
+	}
+	for i := 2; i+4 <= len(raw) && raw[i] == 0xff; {
+		marker := raw[i+1]
+		size := int(binary.BigEndian.Uint16(raw[i+2:]))
+		// Start of scan, no more metadata after this
+		if marker == 0xda || i+2+size > len(raw) {
+			break
+		}
+		segment := raw[i+4 : i+2+size]
+		i += 2 + size
+		if marker != 0xe1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			continue
+		}
+
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if string(tiff[:2]) == "II" {
+			order = binary.LittleEndian
+		}
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		count := int(order.Uint16(tiff[ifd:]))
+		for e := ifd + 2; e+12 <= len(tiff) && count > 0; e, count = e+12, count-1 {
+			// Orientation tag
+			if order.Uint16(tiff[e:]) == 0x0112 {
+				return int(order.Uint16(tiff[e+8:]))
+			}
+		}
+		return 1
+	}
+	return 1
+}
+
+// orient applies the EXIF orientation to img
+// https://magnushoff.com/articles/jpeg-orientation/
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	// Orientations from 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// resize downscales img to width, keeping the aspect ratio. Each pixel is
+// the average of the pixels it covers in the original image, that works well
+// for downscaling photos and screenshots
+func resize(img *image.RGBA, width int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= width {
+		return img
+	}
+	height := max(h*width/w, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		sy0, sy1 := y*h/height, max((y+1)*h/height, y*h/height+1)
+		for x := range width {
+			sx0, sx1 := x*w/width, max((x+1)*w/width, x*w/width+1)
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					i := img.PixOffset(sx, sy)
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					b += int(img.Pix[i+2])
+					a += int(img.Pix[i+3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// stripJpegMetadata removes the metadata segments (EXIF, XMP, IPTC and
+// comments) from a JPEG without encoding it again, so there is no quality
+// loss. Returns nil if raw is not a valid JPEG
+func stripJpegMetadata(raw []byte) []byte {
+	if !bytes.HasPrefix(raw, []byte{0xff, 0xd8}) {
+		return nil
+	}
+	out := []byte{0xff, 0xd8}
+	for i := 2; i+4 <= len(raw) && raw[i] == 0xff; {
+		marker := raw[i+1]
+		// Start of scan, the rest is the image itself
+		if marker == 0xda {
+			return append(out, raw[i:]...)
+		}
+		size := int(binary.BigEndian.Uint16(raw[i+2:]))
+		if i+2+size > len(raw) {
+			return nil
+		}
+		// APP1 (EXIF/XMP), APP13 (IPTC) and COM. Other segments (e.g.: ICC
+		// profile in APP2) affect how the image is displayed
+		if marker != 0xe1 && marker != 0xed && marker != 0xfe {
+			out = append(out, raw[i:i+2+size]...)
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// stripPngMetadata removes the text and EXIF chunks from a PNG without
+// encoding it again. Returns nil if raw is not a valid PNG
+func stripPngMetadata(raw []byte) []byte {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(raw, []byte(signature)) {
+		return nil
+	}
+	out := []byte(signature)
+	for i := len(signature); i+8 <= len(raw); {
+		// Length, type, data and CRC
+		size := 12 + int(binary.BigEndian.Uint32(raw[i:]))
+		if i+size > len(raw) {
+			return nil
+		}
+		switch string(raw[i+4 : i+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out = append(out, raw[i:i+size]...)
+		}
+		i += size
+	}
+	return out
+}
+
+// optimizeImage writes a version of src without metadata (e.g.: EXIF with GPS
+// coordinates) in dst, resized to width if it is wider. Images that don't
+// need to be resized or rotated have the metadata stripped without encoding
+// them again, since that generally makes them bigger. If the result is not
+// smaller than src, dst is not written (and removed if it exists), so the
+// original is used instead
+func optimizeImage(src, dst string, width int) error {
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("could not decode image: %s, error: %w", src, err)
+	}
+
+	var out []byte
+	orientation := 1
+	switch format {
+	case "jpeg":
+		orientation = jpegOrientation(raw)
+		// Without EXIF, the rotation needs to be applied to the image
+		if orientation == 1 {
+			out = stripJpegMetadata(raw)
+		}
+	case "png":
+		out = stripPngMetadata(raw)
+	}
+
+	if out == nil || cfg.Width > width {
+		encoded, err := encodeImage(raw, format, orientation, width)
+		if err != nil {
+			return fmt.Errorf("could not optimize image: %s, error: %w", src, err)
+		}
+		if out == nil || len(encoded) < len(out) {
+			out = encoded
+		}
+	}
+
+	// Still need to write a rotated image, otherwise it would be shown
+	// sideways without the EXIF metadata
+	if len(out) >= len(raw) && orientation == 1 {
+		err = os.Remove(dst)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		log.Printf("[INFO]: image already optimized: %s\n", src)
+		return nil
+	}
+
+	err = writeSiteFile(dst, func(w io.Writer) error {
+		_, err := w.Write(out)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO]: optimized image: %s => %s\n", src, dst)
+	return nil
+}
+
+// encodeImage decodes raw and encodes it again, after applying the EXIF
+// orientation and resizing it to width
+func encodeImage(raw []byte, format string, orientation, width int) ([]byte, error) {
+	decoded, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	img = resize(orient(img, orientation), width)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageJpegQuality})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// upToDate returns true if dst exists and is newer than src
+func upToDate(src, dst string) bool {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return false
+	}
+	return !dstInfo.ModTime().Before(srcInfo.ModTime())
+}
+
+// imageWidth returns the width of the image in file, without decoding it
+func imageWidth(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, fmt.Errorf("could not decode image: %s, error: %w", file, err)
+	}
+	return cfg.Width, nil
+}
+
+// optimizeImages generates the optimized version and thumbnail of each image
+// in root, skipping the ones that didn't change. The original images are kept
+// untouched
+func optimizeImages(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !hasAnyExtension(strings.ToLower(d.Name()), ".png", ".jpg", ".jpeg") {
+			return nil
+		}
+
+		dst := optimizedImagePath(path)
+		if !upToDate(path, dst) {
+			err = optimizeImage(path, dst, imageMaxWidth)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Small images are already shown in full size, so they don't
+		// need a thumbnail
+		thumb := thumbnailImagePath(path)
+		if upToDate(path, thumb) {
+			return nil
+		}
+		width, err := imageWidth(path)
+		if err != nil || width <= imageThumbWidth {
+			return err
+		}
+		return optimizeImage(path, thumb, imageThumbWidth)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testJpeg encodes a noisy image, so it doesn't compress too well, with an
+// EXIF segment if exif is true
+func testJpeg(t *testing.T, width, height int, exif bool) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{uint8(x * y), uint8(x + y), uint8(x ^ y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	if !exif {
+		return raw
+	}
+
+	// Big endian TIFF with a single IFD entry: orientation = 1
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x01\x00\x00\x00\x00\x00\x00")
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	segment = append(segment, bytes.Repeat([]byte("GPS"), 100)...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xff, 0xe1}, uint16(len(segment)+2))
+	out := append([]byte{}, raw[:2]...)
+	out = append(out, app1...)
+	out = append(out, segment...)
+	return append(out, raw[2:]...)
+}
+
+func TestOptimizeImage(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		raw       []byte
+		wantWidth int // 0 if no optimized image should be written
+	}{
+		{"metadata is stripped", testJpeg(t, 100, 50, true), 100},
+		{"already optimized", testJpeg(t, 100, 50, false), 0},
+		{"resized", testJpeg(t, 300, 150, false), 200},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "src.jpg"), filepath.Join(dir, "dst.jpg")
+			err := os.WriteFile(src, tt.raw, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = optimizeImage(src, dst, 200)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := os.ReadFile(dst)
+			if tt.wantWidth == 0 {
+				if err == nil {
+					t.Errorf("got optimized image with %d bytes, want none", len(out))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) >= len(tt.raw) {
+				t.Errorf("got %d bytes, want less than the original: %d", len(out), len(tt.raw))
+			}
+			if bytes.Contains(out, []byte("Exif")) {
+				t.Error("got EXIF metadata in optimized image")
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantWidth {
+				t.Errorf("got width: %d, want: %d", cfg.Width, tt.wantWidth)
+			}
+		})
+	}
+}
+
+func TestOptimizeImagesThumbnails(t *testing.T) {
+	setupPosts(t, map[string]string{
+		"2024-01-01/big.jpg":   string(testJpeg(t, 600, 300, true)),
+		"2024-01-01/small.jpg": string(testJpeg(t, 100, 50, true)),
+	})
+
+	err := optimizeImages(config.PostsRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		file      string
+		wantWidth int // 0 if the file shouldn't exist
+	}{
+		{optimizedImagePath("posts/2024-01-01/big.jpg"), 600},
+		{thumbnailImagePath("posts/2024-01-01/big.jpg"), imageThumbWidth},
+		{optimizedImagePath("posts/2024-01-01/small.jpg"), 100},
+		// Smaller than a thumbnail already
+		{thumbnailImagePath("posts/2024-01-01/small.jpg"), 0},
+	} {
+		width, err := imageWidth(tt.file)
+		if tt.wantWidth == 0 {
+			if err == nil {
+				t.Errorf("got image: %s, want none", tt.file)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if width != tt.wantWidth {
+			t.Errorf("got width: %d, want: %d for %s", width, tt.wantWidth, tt.file)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	linkCacheFile        = ".cache/links.json"
+	linkCacheTtl         = 7 * 24 * time.Hour
+	linkCacheDeadTtl     = 24 * time.Hour
+	linkCheckConcurrency = 8
+	linkCheckInterval    = time.Second
+	linkCheckUserAgent   = "Mozilla/5.0 (compatible; blog-link-checker)"
+	lintDeadLink         = "dead-link"
+	lintRedirectedLink   = "redirected-link"
+)
+
+type linkStatus struct {
+	Code      int       `json:"code,omitempty"`
+	Location  string    `json:"location,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (s linkStatus) dead() bool {
+	return s.Error != "" || s.Code >= 400
+}
+
+func (s linkStatus) redirected() bool {
+	return s.Code >= 300 && s.Code < 400
+}
+
+// linkCache maps each URL to its last status, so we don't need to check
+// every link every time
+type linkCache map[string]linkStatus
+
+func loadLinkCache(file string) (linkCache, error) {
+	cache := linkCache{}
+	raw, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, fmt.Errorf("could not read link cache: %w", err)
+	}
+	err = json.Unmarshal(raw, &cache)
+	if err != nil {
+		return cache, fmt.Errorf("link cache JSON unmarshal error: %w", err)
+	}
+	return cache, nil
+}
+
+func (c linkCache) save(file string) error {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("link cache JSON marshal error: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(raw, '\n'), 0o644)
+}
+
+// fresh returns true if the status of u doesn't need to be checked again.
+// Dead links are checked more often, since they may be temporary failures
+func (c linkCache) fresh(u string, now time.Time) bool {
+	s, ok := c[u]
+	if !ok {
+		return false
+	}
+	ttl := linkCacheTtl
+	if s.dead() {
+		ttl = linkCacheDeadTtl
+	}
+	return now.Sub(s.CheckedAt) < ttl
+}
+
+// externalLink is a link to outside the blog found in a post
+type externalLink struct {
+	file path
+	line int
+	url  string
+}
+
+func extractExternalLinks(ps posts) []externalLink {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	var links []externalLink
+	for post := range ps.Values() {
+		doc := md.Parser().Parse(text.NewReader(post.contents))
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if !entering {
+				return ast.WalkContinue, nil
+			}
+			var dest string
+			switch n := n.(type) {
+			case *ast.Link:
+				dest = string(n.Destination)
+			case *ast.AutoLink:
+				if n.AutoLinkType == ast.AutoLinkURL {
+					dest = string(n.URL(post.contents))
+				}
+			}
+			if u, err := url.Parse(dest); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				links = append(links, externalLink{
+					file: post.file,
+					line: post.line + bytes.Count(post.contents[:nodeOffset(n)], []byte("\n")),
+					url:  dest,
+				})
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+	return links
+}
+
+// linkChecker checks URLs concurrently, while waiting at least interval
+// between requests to the same host to avoid being rate limited
+type linkChecker struct {
+	client      *retryClient
+	concurrency int
+	interval    time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newLinkChecker() *linkChecker {
+	client := newRetryClient()
+	client.maxRetries = 2
+	// We want to report redirects, so don't follow them
+	client.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &linkChecker{
+		client:      client,
+		concurrency: linkCheckConcurrency,
+		interval:    linkCheckInterval,
+		next:        map[string]time.Time{},
+	}
+}
+
+// wait blocks until we can do the next request to host
+func (c *linkChecker) wait(ctx context.Context, host string) error {
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.next[host] = slot.Add(c.interval)
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(slot)):
+		return nil
+	}
+}
+
+func (c *linkChecker) check(ctx context.Context, u string) linkStatus {
+	status := linkStatus{CheckedAt: time.Now()}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", linkCheckUserAgent)
+	var resp *http.Response
+	// Some servers don't support HEAD, so try again with GET if it fails
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		err = c.wait(ctx, parsed.Host)
+		if err != nil {
+			break
+		}
+		resp, _, err = c.client.do(ctx, method, u, header, nil)
+		if err != nil || resp.StatusCode < 400 {
+			break
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Code = resp.StatusCode
+	// Location may be relative
+	if loc, err := resp.Location(); err == nil {
+		status.Location = loc.String()
+	}
+	return status
+}
+
+// checkAll checks every URL that is not fresh in cache, updating it
+func (c *linkChecker) checkAll(ctx context.Context, urls []string, cache linkCache) {
+	// Filter before starting, since the cache is updated concurrently
+	now := time.Now()
+	urls = slices.DeleteFunc(slices.Clone(urls), func(u string) bool { return cache.fresh(u, now) })
+	log.Printf("[INFO]: checking %d links not found in cache\n", len(urls))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.concurrency)
+	for _, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			status := c.check(ctx, u)
+			// Don't cache the result if we got cancelled
+			if ctx.Err() != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			cache[u] = status
+		}()
+	}
+	wg.Wait()
+}
+
+// checkExternalLinks checks all links to outside the blog with c, returning
+// the dead and redirected ones as lint issues
+func checkExternalLinks(ctx context.Context, c *linkChecker, ps posts, cacheFile string) ([]lintIssue, error) {
+	cache, err := loadLinkCache(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	links := extractExternalLinks(ps)
+	var urls []string
+	for _, l := range links {
+		if !slices.Contains(urls, l.url) {
+			urls = append(urls, l.url)
+		}
+	}
+	log.Printf("[INFO]: found %d unique links\n", len(urls))
+
+	c.checkAll(ctx, urls, cache)
+	// Save even if cancelled, so we don't lose the progress
+	err = cache.save(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var issues []lintIssue
+	for _, l := range links {
+		status := cache[l.url]
+		issue := lintIssue{File: l.file, Line: l.line}
+		switch {
+		case status.Error != "":
+			issue.Rule = lintDeadLink
+			issue.Message = fmt.Sprintf("%s (error: %s)", l.url, status.Error)
+		case status.dead():
+			issue.Rule = lintDeadLink
+			issue.Message = fmt.Sprintf("%s (code=%d)", l.url, status.Code)
+		case status.redirected():
+			issue.Rule = lintRedirectedLink
+			issue.Message = fmt.Sprintf("%s -> %s (code=%d)", l.url, status.Location, status.Code)
+		default:
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	slices.SortStableFunc(issues, func(a, b lintIssue) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	return issues, nil
+}
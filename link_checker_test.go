@@ -0,0 +1,147 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// linkServer records the requests, so we can check what was (not) requested
+type linkServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	times    []time.Time
+}
+
+func newLinkServer(t *testing.T) *linkServer {
+	s := &linkServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.times = append(s.times, time.Now())
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestLinkChecker(interval time.Duration) *linkChecker {
+	c := newLinkChecker()
+	c.interval = interval
+	c.client.baseDelay = time.Millisecond
+	return c
+}
+
+func TestCheckExternalLinks(t *testing.T) {
+	srv := newLinkServer(t)
+	setupPosts(t, map[string]string{
+		"2024-01-01/01-links.md": "# Links\n\n" +
+			"[ok](" + srv.URL + "/ok)\n\n" +
+			"[missing](" + srv.URL + "/missing)\n\n" +
+			"<" + srv.URL + "/moved>\n",
+	})
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := checkExternalLinks(context.Background(), newTestLinkChecker(0), ps, filepath.Join(t.TempDir(), "links.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(config.PostsRoot, "2024-01-01/01-links.md")
+	want := []lintIssue{
+		{File: file, Line: 5, Rule: lintDeadLink, Message: srv.URL + "/missing (code=404)"},
+		{File: file, Line: 7, Rule: lintRedirectedLink, Message: srv.URL + "/moved -> " + srv.URL + "/ok (code=301)"},
+	}
+	if !slices.Equal(issues, want) {
+		t.Errorf("got issues: %+v, want: %+v", issues, want)
+	}
+}
+
+func TestLinkCheckerHeadFallback(t *testing.T) {
+	srv := newLinkServer(t)
+
+	status := newTestLinkChecker(0).check(context.Background(), srv.URL+"/no-head")
+	if status.dead() || status.Code != http.StatusOK {
+		t.Errorf("got status: %+v, want code: 200", status)
+	}
+	want := []string{"HEAD /no-head", "GET /no-head"}
+	if !slices.Equal(srv.requests, want) {
+		t.Errorf("got requests: %v, want: %v", srv.requests, want)
+	}
+}
+
+func TestLinkCheckerInterval(t *testing.T) {
+	srv := newLinkServer(t)
+	interval := 50 * time.Millisecond
+
+	urls := []string{srv.URL + "/ok", srv.URL + "/ok?1", srv.URL + "/ok?2"}
+	newTestLinkChecker(interval).checkAll(context.Background(), urls, linkCache{})
+
+	if len(srv.times) != len(urls) {
+		t.Fatalf("got %d requests, want: %d", len(srv.times), len(urls))
+	}
+	slices.SortFunc(srv.times, func(a, b time.Time) int { return a.Compare(b) })
+	for i := 1; i < len(srv.times); i++ {
+		// Small tolerance for the timer resolution
+		if d := srv.times[i].Sub(srv.times[i-1]); d < interval-5*time.Millisecond {
+			t.Errorf("got %v between requests to the same host, want at least: %v", d, interval)
+		}
+	}
+}
+
+func TestLinkCheckerCache(t *testing.T) {
+	srv := newLinkServer(t)
+	fresh, stale := srv.URL+"/ok", srv.URL+"/missing"
+	checkedAt := time.Now().Add(-linkCacheTtl - time.Hour)
+	cache := linkCache{
+		fresh: {Code: http.StatusOK, CheckedAt: time.Now()},
+		stale: {Code: http.StatusOK, CheckedAt: checkedAt},
+	}
+
+	newTestLinkChecker(0).checkAll(context.Background(), []string{fresh, stale}, cache)
+
+	// HEAD and GET for the missing one, nothing for the fresh one
+	want := []string{"HEAD /missing", "GET /missing"}
+	if !slices.Equal(srv.requests, want) {
+		t.Errorf("got requests: %v, want: %v", srv.requests, want)
+	}
+	if s := cache[stale]; s.Code != http.StatusNotFound || !s.CheckedAt.After(checkedAt) {
+		t.Errorf("got status: %+v, want it updated with code: 404", s)
+	}
+}
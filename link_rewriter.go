@@ -15,28 +15,95 @@ package main
 //  0. You just DO WHAT THE FUCK YOU WANT TO.
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"net/url"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 )
 
+// linkSourceKey stores the linkSource of the post being converted in the
+// parser context, so diagnostics can point to the file and line
+var linkSourceKey = parser.NewContextKey()
+
+type linkSource struct {
+	file path
+	line int
+}
+
+// postContext returns the parser context for converting post, should be
+// passed with parser.WithContext
+func postContext(p post) parser.Context {
+	pc := parser.NewContext()
+	pc.Set(linkSourceKey, linkSource{p.file, p.line})
+	return pc
+}
+
+type linkDiagnostic struct {
+	file    path
+	line    int
+	message string
+}
+
+func (d linkDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.file, d.line, d.message)
+}
+
+// linkDiagnostics collects broken links and images found while converting
+// posts. Safe for concurrent use
+type linkDiagnostics struct {
+	mu   sync.Mutex
+	list []linkDiagnostic
+}
+
+func (d *linkDiagnostics) add(diag linkDiagnostic) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// The same post may be converted multiple times
+	if !slices.Contains(d.list, diag) {
+		d.list = append(d.list, diag)
+	}
+}
+
+// err returns all diagnostics in a consolidated report, or nil if there is
+// none
+func (d *linkDiagnostics) err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.list) == 0 {
+		return nil
+	}
+	var report []string
+	for _, diag := range d.list {
+		report = append(report, diag.String())
+	}
+	slices.Sort(report)
+	return fmt.Errorf("found %d broken references:\n%s", len(report), strings.Join(report, "\n"))
+}
+
 // linkRewriter is the main struct for your extension
 type linkRewriter struct {
-	prefixUrl string
-	posts     posts
+	prefixUrl    string
+	rawPrefixUrl string
+	posts        posts
+	// If not nil, warnings are also collected here (e.g.: for strict mode)
+	diagnostics *linkDiagnostics
 }
 
-// NewLinkRewriter returns a new instance of LinkRewriter
-func NewLinkRewriter(prefixUrl string, posts posts) *linkRewriter {
-	return &linkRewriter{prefixUrl, posts}
+// NewLinkRewriter returns a new instance of LinkRewriter. Links are
+// rewritten to prefixUrl, while images are rewritten to rawPrefixUrl
+func NewLinkRewriter(prefixUrl, rawPrefixUrl string, posts posts) *linkRewriter {
+	return &linkRewriter{prefixUrl: prefixUrl, rawPrefixUrl: rawPrefixUrl, posts: posts}
 }
 
 // Extend will be called by Goldmark to add your extension
@@ -46,20 +113,60 @@ func (e *linkRewriter) Extend(m goldmark.Markdown) {
 
 // Transform is the method that modifies the AST
 func (e *linkRewriter) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	src, _ := pc.Get(linkSourceKey).(linkSource)
+	// Only set if the output is going to be cached
+	deps, _ := pc.Get(renderDepsKey).(*renderDeps)
+	source := reader.Source()
+	// Images linking to themselves, e.g.: [![alt](/a.jpg)](/a.jpg)
+	thumbs := map[*ast.Image]bool{}
+
 	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
 			return ast.WalkContinue, nil
 		}
+		warn := func(format string, args ...any) {
+			diag := linkDiagnostic{
+				file:    src.file,
+				line:    src.line + bytes.Count(source[:nodeOffset(n)], []byte("\n")),
+				message: fmt.Sprintf(format, args...),
+			}
+			log.Printf("[WARN]: %s\n", diag)
+			if e.diagnostics != nil {
+				e.diagnostics.add(diag)
+			}
+		}
 		if link, ok := n.(*ast.Link); ok {
-			e.rewriteLink(link)
+			// Check before rewriting, since the destinations will
+			// be different afterwards
+			if image, ok := link.FirstChild().(*ast.Image); ok && link.ChildCount() == 1 && bytes.Equal(image.Destination, link.Destination) {
+				thumbs[image] = true
+			}
+			e.rewriteLink(link, deps, warn)
 		}
 		if image, ok := n.(*ast.Image); ok {
-			e.rewriteImage(image)
+			e.rewriteImage(image, thumbs[image], deps, warn)
 		}
 		return ast.WalkContinue, nil
 	})
 }
 
+// nodeOffset returns the position of a node in the source. Inline nodes have
+// no position, so we use the first text inside it or its parent
+func nodeOffset(n ast.Node) int {
+	for ; n != nil; n = n.Parent() {
+		if t, ok := n.(*ast.Text); ok {
+			return t.Segment.Start
+		}
+		if c, ok := n.FirstChild().(*ast.Text); ok {
+			return c.Segment.Start
+		}
+		if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+			return n.Lines().At(0).Start
+		}
+	}
+	return 0
+}
+
 func hasAnyExtension(s string, extensions ...string) bool {
 	ext := filepath.Ext(s)
 	if ext == "" {
@@ -74,59 +181,85 @@ func hasAnyExtension(s string, extensions ...string) bool {
 }
 
 // rewriteLink modifies the link URL
-func (e *linkRewriter) rewriteLink(l *ast.Link) {
+func (e *linkRewriter) rewriteLink(l *ast.Link, deps *renderDeps, warn func(string, ...any)) {
 	link := string(l.Destination)
 
 	if strings.HasPrefix(link, ".") {
-		log.Printf("[WARN]: relative link reference found: %s\n", link)
+		warn("relative link reference found: %s", link)
 	}
 
 	if strings.HasPrefix(link, "/") {
 		var dest string
+		var err error
 
 		if hasAnyExtension(link, ".png", ".jpg", ".jpeg") {
 			// If the link is an image, we will point it to
-			// blogRawUrl
-			if _, err := os.Stat(filepath.Join(".", link)); err == nil {
-				dest = must1(url.JoinPath(blogRawUrl, link))
+			// rawPrefixUrl, preferring the optimized image
+			if deps.exists(filepath.Join(".", link)) {
+				dest, err = url.JoinPath(e.rawPrefixUrl, imageVariant(link, false, deps))
 			} else {
-				log.Printf("[WARN] did not find image: %s\n", link)
+				warn("did not find image: %s", link)
 				return
 			}
 		} else if e.posts != nil {
 			// If posts are not nil, it means we will grab the slug
 			// from posts
-			post, ok := e.posts.Get(link[1:])
+			post, ok := deps.lookup(e.posts, link[1:])
 			if ok {
-				dest = must1(url.JoinPath(e.prefixUrl, post.slug))
+				dest, err = url.JoinPath(e.prefixUrl, post.slug)
 			} else {
-				log.Printf("[WARN] did not find reference to link: %s\n", link)
+				warn("did not find reference to link: %s", link)
 				return
 			}
 		} else {
 			// Else we will just append the prefixUrl to the link
-			if _, err := os.Stat(filepath.Join(".", link)); err == nil {
-				dest = must1(url.JoinPath(e.prefixUrl, link))
+			if deps.exists(filepath.Join(".", link)) {
+				dest, err = url.JoinPath(e.prefixUrl, link)
 			} else {
-				log.Printf("[WARN] did not find link: %s\n", link)
+				warn("did not find link: %s", link)
 				return
 			}
 		}
+		if err != nil {
+			warn("could not rewrite link: %s, error: %v", link, err)
+			return
+		}
 
 		l.Destination = []byte(dest)
 	}
 }
 
-// rewriteImage modifies the image URL
-func (e *linkRewriter) rewriteImage(i *ast.Image) {
+// rewriteImage modifies the image URL. If thumb is true (e.g.: the image
+// links to its full size version), the thumbnail is used if it exists
+func (e *linkRewriter) rewriteImage(i *ast.Image, thumb bool, deps *renderDeps, warn func(string, ...any)) {
 	image := string(i.Destination)
 
 	if strings.HasPrefix(image, ".") {
-		log.Printf("[WARN]: relative image link reference found: %s\n", image)
+		warn("relative image link reference found: %s", image)
 	}
 
 	if strings.HasPrefix(image, "/") {
-		dest := must1(url.JoinPath(blogRawUrl, image))
+		if !deps.exists(filepath.Join(".", image)) {
+			warn("did not find image: %s", image)
+		}
+		// Prefer the optimized image if it was generated
+		dest, err := url.JoinPath(e.rawPrefixUrl, imageVariant(image, thumb, deps))
+		if err != nil {
+			warn("could not rewrite image: %s, error: %v", image, err)
+			return
+		}
 		i.Destination = []byte(dest)
 	}
 }
+
+// checkLinks converts all posts, returning an error with every broken link or
+// image found
+func checkLinks(ps posts) error {
+	rewriter := NewLinkRewriter(siteBaseUrl, siteBaseUrl, ps)
+	rewriter.diagnostics = &linkDiagnostics{}
+	md := goldmark.New(goldmark.WithExtensions(rewriter, extension.GFM))
+	for post := range ps.Values() {
+		md.Parser().Parse(text.NewReader(post.contents), parser.WithContext(postContext(post)))
+	}
+	return rewriter.diagnostics.err()
+}
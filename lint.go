@@ -0,0 +1,168 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	lintRelativeLink       = "relative-link"
+	lintImageAlt           = "image-alt"
+	lintImageMissing       = "image-missing"
+	lintCodeFenceLang      = "code-fence-lang"
+	lintTrailingWhitespace = "trailing-whitespace"
+	lintMultipleH1         = "multiple-h1"
+	lintHeadingSkip        = "heading-skip"
+)
+
+type lintIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// String uses the same format as compilers, so the issues can be parsed by
+// editors and CI
+func (i lintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", i.File, i.Line, i.Rule, i.Message)
+}
+
+// linter checks one post, keeping track of the line where the contents start
+// so the issues point to the correct line in the file
+type linter struct {
+	file      string
+	contents  []byte
+	firstLine int
+	issues    []lintIssue
+}
+
+func (l *linter) report(offset int, rule, format string, args ...any) {
+	l.issues = append(l.issues, lintIssue{
+		File:    l.file,
+		Line:    l.firstLine + bytes.Count(l.contents[:offset], []byte("\n")),
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) checkDestination(n ast.Node, dest string, image bool) {
+	if strings.HasPrefix(dest, ".") {
+		l.report(nodeOffset(n), lintRelativeLink, "relative link reference: %s", dest)
+	}
+	if !strings.HasPrefix(dest, "/") {
+		return
+	}
+	if image || hasAnyExtension(dest, ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp") {
+		if _, err := os.Stat(filepath.Join(".", dest)); err != nil {
+			l.report(nodeOffset(n), lintImageMissing, "did not find image: %s", dest)
+		}
+	}
+}
+
+func (l *linter) checkAst(doc ast.Node) {
+	// The title is rendered as H1
+	lastLevel := 1
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			if n.Level == 1 {
+				l.report(nodeOffset(n), lintMultipleH1, "multiple H1 headings, the title is already one")
+			} else if n.Level > lastLevel+1 {
+				l.report(nodeOffset(n), lintHeadingSkip, "heading skips from H%d to H%d", lastLevel, n.Level)
+			}
+			lastLevel = n.Level
+		case *ast.FencedCodeBlock:
+			if n.Info == nil {
+				// The fence itself is the line before the code
+				line := max(nodeOffset(n)-1, 0)
+				if n.Lines().Len() == 0 {
+					line = nodeOffset(n.Parent())
+				}
+				l.report(line, lintCodeFenceLang, "code fence without language")
+			}
+		case *ast.Link:
+			l.checkDestination(n, string(n.Destination), false)
+		case *ast.Image:
+			if n.FirstChild() == nil {
+				l.report(nodeOffset(n), lintImageAlt, "image without alt text: %s", n.Destination)
+			}
+			l.checkDestination(n, string(n.Destination), true)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func (l *linter) checkLines() {
+	offset := 0
+	for _, line := range bytes.SplitAfter(l.contents, []byte("\n")) {
+		trimmed := bytes.TrimRight(line, "\r\n")
+		if len(trimmed) > 0 && len(bytes.TrimRight(trimmed, " \t")) != len(trimmed) {
+			l.report(offset, lintTrailingWhitespace, "trailing whitespace")
+		}
+		offset += len(line)
+	}
+}
+
+// lintPosts checks the posts for common issues, e.g.: missing alt text or
+// code fences without language
+func lintPosts(ps posts) []lintIssue {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	var issues []lintIssue
+	for path, post := range ps.AllFromFront() {
+		l := linter{file: path, contents: post.contents, firstLine: post.line}
+		l.checkLines()
+		l.checkAst(md.Parser().Parse(text.NewReader(post.contents)))
+		slices.SortStableFunc(l.issues, func(a, b lintIssue) int { return a.Line - b.Line })
+		issues = append(issues, l.issues...)
+	}
+	return issues
+}
+
+func formatLintIssues(issues []lintIssue, format string) (string, error) {
+	switch format {
+	case "text":
+		var sb strings.Builder
+		for _, i := range issues {
+			sb.WriteString(i.String() + "\n")
+		}
+		return sb.String(), nil
+	case "json":
+		// Make sure we output an empty list instead of null
+		if issues == nil {
+			issues = []lintIssue{}
+		}
+		raw, err := json.MarshalIndent(issues, "", "  ")
+		return string(raw) + "\n", err
+	default:
+		return "", fmt.Errorf("invalid lint format: %s", format)
+	}
+}
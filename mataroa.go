@@ -15,38 +15,28 @@ package main
 //  0. You just DO WHAT THE FUCK YOU WANT TO.
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"time"
-
-	"github.com/alecthomas/chroma/formatters/html"
-	"github.com/elliotchance/orderedmap/v3"
-	"github.com/yuin/goldmark"
-	highlighting "github.com/yuin/goldmark-highlighting"
-	"github.com/yuin/goldmark/extension"
-)
-
-const (
-	mataroaBaseUrl = "https://capivaras.dev"
-	mataroaApiUrl  = mataroaBaseUrl + "/api/"
-	mataroaBlogUrl = "https://kokada.dev/blog/"
 )
 
 var mataroaToken = os.Getenv("MATAROA_TOKEN")
 
 // https://capivaras.dev/api/docs/
 type mataroaResponse struct {
-	Ok    bool   `json:"ok"`
-	Title string `json:"title"`
-	Url   string `json:"url"`
-	Slug  string `json:"slug"`
-	Error string `json:"error"`
+	Ok          bool   `json:"ok"`
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	Slug        string `json:"slug"`
+	Body        string `json:"body"`
+	PublishedAt string `json:"published_at"`
+	Error       string `json:"error"`
+	// Only returned when listing posts
+	PostList []mataroaResponse `json:"post_list"`
 }
 
 type mataroaPostRequest struct {
@@ -62,32 +52,46 @@ type mataroaPatchRequest struct {
 	PublishedAt string `json:"published_at"`
 }
 
-func mustMataroaUrl(elem ...string) string {
+// mataroaUnpublishRequest sets published_at to null, turning the post into a
+// draft
+type mataroaUnpublishRequest struct {
+	PublishedAt *string `json:"published_at"`
+}
+
+func mataroaUrl(elem ...string) (string, error) {
 	// generate a Mataroa URL, ensure '/' at the end
-	mUrl := must1(url.JoinPath(mataroaApiUrl, elem...))
-	mUrl = must1(url.JoinPath(mUrl, "/"))
-	return mUrl
+	return url.JoinPath(config.MataroaUrl, append(append([]string{"api"}, elem...), "/")...)
 }
 
-func mataroaReq(method string, url string, body []byte) (m mataroaResponse, r *http.Response, err error) {
-	// Prepare request payload if non-nil
-	var reqBuf io.Reader
-	if body != nil {
-		reqBuf = bytes.NewBuffer(body)
+// mataroaClient retries requests on transient failures, since we don't want
+// to abort a publish halfway through because of e.g.: rate limits
+var mataroaClient = newRetryClient()
+
+func mataroaReq(ctx context.Context, method string, in any, elem ...string) (m mataroaResponse, r *http.Response, err error) {
+	if mataroaToken == "" {
+		return m, r, fmt.Errorf("empty MATAROA_TOKEN environment variable")
 	}
-	req := must1(http.NewRequest(method, url, reqBuf))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", mataroaToken))
 
-	// Do request and return response
-	r, err = http.DefaultClient.Do(req)
+	url, err := mataroaUrl(elem...)
 	if err != nil {
-		return m, r, fmt.Errorf("Mataroa request error: %w", err)
+		return m, r, fmt.Errorf("invalid Mataroa URL: %w", err)
 	}
+	var body []byte
+	if in != nil {
+		body, err = json.Marshal(in)
+		if err != nil {
+			return m, r, fmt.Errorf("Mataroa JSON marshal error: %w", err)
+		}
+	}
+
+	header := http.Header{}
+	header.Add("Accept", "application/json")
+	header.Add("Authorization", fmt.Sprintf("Bearer %s", mataroaToken))
 
-	rBody, err := io.ReadAll(r.Body)
+	// Do request and return response
+	r, rBody, err := mataroaClient.do(ctx, method, url, header, body)
 	if err != nil {
-		return m, r, fmt.Errorf("Mataroa response error: %w", err)
+		return m, r, fmt.Errorf("Mataroa request error: %w", err)
 	}
 
 	err = json.Unmarshal(rBody, &m)
@@ -98,88 +102,132 @@ func mataroaReq(method string, url string, body []byte) (m mataroaResponse, r *h
 	return m, r, nil
 }
 
-func getMataroaPost(slug string) (mataroaResponse, *http.Response, error) {
-	return mataroaReq("GET", mustMataroaUrl("posts", slug), nil)
+func getMataroaPost(ctx context.Context, slug string) (mataroaResponse, *http.Response, error) {
+	return mataroaReq(ctx, "GET", nil, "posts", slug)
 }
 
-func patchMataroaPost(slug string, p post) (mataroaResponse, *http.Response, error) {
-	reqBody := must1(json.Marshal(mataroaPatchRequest{
+func listMataroaPosts(ctx context.Context) (mataroaResponse, *http.Response, error) {
+	return mataroaReq(ctx, "GET", nil, "posts")
+}
+
+func deleteMataroaPost(ctx context.Context, slug string) (mataroaResponse, *http.Response, error) {
+	return mataroaReq(ctx, "DELETE", nil, "posts", slug)
+}
+
+// mataroaPublishedAt returns the publish date of p for Mataroa, that only
+// accepts a date. It is formatted in the timezone of the post, so a post
+// scheduled for a time is not moved to another day. It is only published
+// once the time passes, since scheduled posts are not loaded before it
+func mataroaPublishedAt(p post) string {
+	return p.date.Format(time.DateOnly)
+}
+
+func unpublishMataroaPost(ctx context.Context, slug string) (mataroaResponse, *http.Response, error) {
+	return mataroaReq(ctx, "PATCH", mataroaUnpublishRequest{}, "posts", slug)
+}
+
+func patchMataroaPost(ctx context.Context, slug string, p post) (mataroaResponse, *http.Response, error) {
+	reqBody := mataroaPatchRequest{
 		Title:       p.title,
 		Body:        string(p.contents),
 		Slug:        p.slug,
-		PublishedAt: p.date.Format(time.DateOnly),
-	}))
-	return mataroaReq("PATCH", mustMataroaUrl("posts", slug), reqBody)
+		PublishedAt: mataroaPublishedAt(p),
+	}
+	return mataroaReq(ctx, "PATCH", reqBody, "posts", slug)
 }
 
-func postMataroaPost(p post) (mataroaResponse, *http.Response, error) {
-	reqBody := must1(json.Marshal(mataroaPostRequest{
+func postMataroaPost(ctx context.Context, p post) (mataroaResponse, *http.Response, error) {
+	reqBody := mataroaPostRequest{
 		Title:       p.title,
 		Body:        string(p.contents),
-		PublishedAt: p.date.Format(time.DateOnly),
-	}))
-	return mataroaReq("POST", mustMataroaUrl("posts"), reqBody)
-}
-
-func prepareToMataroa(ps posts) posts {
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			NewLinkRewriter(mataroaBlogUrl, ps),
-			extension.GFM,
-			highlighting.NewHighlighting(
-				// No style since we are reusing the style from
-				// Mataroa
-				highlighting.WithFormatOptions(html.WithClasses(true)),
-			),
-		),
-	)
-
-	preparedPosts := orderedmap.NewOrderedMap[path, post]()
-	for el := ps.Front(); el != nil; el = el.Next() {
-		path, post := el.Key, el.Value
-		buf := bytes.Buffer{}
-		must(md.Convert([]byte(post.contents), &buf))
-		post.contents = buf.Bytes()
-		preparedPosts.Set(path, post)
+		PublishedAt: mataroaPublishedAt(p),
 	}
-	return preparedPosts
+	return mataroaReq(ctx, "POST", reqBody, "posts")
 }
 
-func publishToMataroa(ps posts) {
-	if mataroaToken == "" {
-		log.Fatal("empty MATAROA_TOKEN environment variable")
+// checkMataroaResp converts a non-successful Mataroa response in an error
+func checkMataroaResp(slug string, p mataroaResponse, resp *http.Response, err error) error {
+	if err != nil {
+		return err
 	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf(
+			"non-200 (code=%d) status code for post=%s, error: %s",
+			resp.StatusCode,
+			slug,
+			p.Error,
+		)
+	}
+	return nil
+}
 
-	for post := range prepareToMataroa(ps).Values() {
-		p, resp := must2(getMataroaPost(post.slug))
-		var err error
-		if p.Ok {
-			p, resp, err = patchMataroaPost(post.slug, post)
-			log.Printf("[UPDATED] (code=%d): %+v\n", resp.StatusCode, p)
-		} else if resp.StatusCode == 404 {
-			p, resp, err = postMataroaPost(post)
-			log.Printf("[NEW] (code=%d): %+v\n", resp.StatusCode, p)
-
-			if p.Ok && p.Slug != post.slug {
-				log.Printf(
-					"[INFO] Updating slug since they're different, Mataroa slug: %s, generated one: %s",
-					p.Slug,
-					post.slug,
-				)
-				p, resp, err = patchMataroaPost(p.Slug, post)
-				log.Printf("[UPDATED] (code=%d): %+v\n", resp.StatusCode, p)
-			}
-		}
+// mataroaPublisher publishes posts to a Mataroa instance
+type mataroaPublisher struct{}
 
-		if resp.StatusCode != 200 {
-			err = fmt.Errorf(
-				"non-200 (code=%d) status code for post=%s, response: %+v",
-				resp.StatusCode,
-				post.slug,
-				resp,
-			)
-		}
+func (m *mataroaPublisher) Name() string {
+	return "mataroa"
+}
+
+func (m *mataroaPublisher) Prepare(ps posts) (posts, error) {
+	return renderPosts(ps, config.MataroaBlogUrl)
+}
+
+func (m *mataroaPublisher) PublishedAt(p post) string {
+	return mataroaPublishedAt(p)
+}
+
+func (m *mataroaPublisher) List(ctx context.Context) ([]remotePost, error) {
+	p, resp, err := listMataroaPosts(ctx)
+	err = checkMataroaResp("(all)", p, resp, err)
+	if err != nil {
+		return nil, err
+	}
 
-		must(err)
+	var list []remotePost
+	for _, p := range p.PostList {
+		list = append(list, remotePost{
+			slug:        p.Slug,
+			title:       p.Title,
+			body:        p.Body,
+			publishedAt: p.PublishedAt,
+		})
 	}
+	return list, nil
+}
+
+func (m *mataroaPublisher) Get(ctx context.Context, slug string) (remotePost, error) {
+	p, resp, err := getMataroaPost(ctx, slug)
+	if err == nil && resp.StatusCode == 404 {
+		return remotePost{}, errPostNotFound
+	}
+	err = checkMataroaResp(slug, p, resp, err)
+	if err != nil {
+		return remotePost{}, err
+	}
+	return remotePost{
+		slug:        p.Slug,
+		title:       p.Title,
+		body:        p.Body,
+		publishedAt: p.PublishedAt,
+	}, nil
+}
+
+func (m *mataroaPublisher) Create(ctx context.Context, post post) (string, error) {
+	p, resp, err := postMataroaPost(ctx, post)
+	return p.Slug, checkMataroaResp(post.slug, p, resp, err)
+}
+
+func (m *mataroaPublisher) Update(ctx context.Context, slug string, post post) error {
+	p, resp, err := patchMataroaPost(ctx, slug, post)
+	return checkMataroaResp(slug, p, resp, err)
+}
+
+func (m *mataroaPublisher) Delete(ctx context.Context, slug string) error {
+	p, resp, err := deleteMataroaPost(ctx, slug)
+	return checkMataroaResp(slug, p, resp, err)
+}
+
+func (m *mataroaPublisher) Unpublish(ctx context.Context, slug string) error {
+	p, resp, err := unpublishMataroaPost(ctx, slug)
+	return checkMataroaResp(slug, p, resp, err)
 }
@@ -0,0 +1,113 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+// parallelMap applies fn to each item using a pool of workers, returning the
+// results in the same order as items. newFn is called once per worker, so
+// each one can have its own state (e.g.: a goldmark instance)
+func parallelMap[T, R any](items []T, newFn func() func(T) R) []R {
+	results := make([]R, len(items))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn := newFn()
+			for i := range jobs {
+				results[i] = fn(items[i])
+			}
+		}()
+	}
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// renderAll converts the contents of each post to HTML in parallel, keeping
+// the order of the posts. goldmark extensions (e.g.: linkRewriter) are not
+// necessarily safe to share between goroutines, so newMd is called once per
+// worker. The output is cached by the contents and renderer, that should
+// describe everything newMd uses (e.g.: extensions, chroma style and link
+// prefix). Links between posts are resolved in ps
+func renderAll(ps posts, renderer string, newMd func() goldmark.Markdown) (posts, error) {
+	type entry struct {
+		path path
+		post post
+	}
+	var entries []entry
+	for path, post := range ps.AllFromFront() {
+		entries = append(entries, entry{path, post})
+	}
+
+	type result struct {
+		contents []byte
+		err      error
+	}
+	results := parallelMap(entries, func() func(entry) result {
+		md := newMd()
+		return func(e entry) result {
+			key := renderCacheKey(renderer, e.post.contents)
+			if contents, ok := getRenderCache(key, ps); ok {
+				return result{contents, nil}
+			}
+
+			deps := newRenderDeps()
+			pc := postContext(e.post)
+			pc.Set(renderDepsKey, deps)
+			var buf bytes.Buffer
+			err := md.Convert(e.post.contents, &buf, parser.WithContext(pc))
+			if err != nil {
+				return result{nil, err}
+			}
+			// The cache is only an optimization, so don't fail
+			if err := setRenderCache(key, buf.Bytes(), deps); err != nil {
+				log.Printf("[WARN]: could not write render cache, error: %v\n", err)
+			}
+			return result{buf.Bytes(), nil}
+		}
+	})
+
+	rendered := orderedmap.NewOrderedMap[path, post]()
+	var errs []error
+	for i, r := range results {
+		e := entries[i]
+		if r.err != nil {
+			errs = append(errs, &postError{file: e.path, err: fmt.Errorf("could not convert Markdown: %w", r.err)})
+			continue
+		}
+		e.post.contents = r.contents
+		rendered.Set(e.path, e.post)
+	}
+	return rendered, errors.Join(errs...)
+}
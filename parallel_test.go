@@ -0,0 +1,185 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// Number of posts in the synthetic corpus used by the benchmarks
+const benchmarkPosts = 2000
+
+// testCorpus generates n posts (3 per day), each one with some text, code and
+// a link to the previous one, so they look like the real ones
+func testCorpus(n int) map[string]string {
+	files := map[string]string{}
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	var previous string
+	for i := range n {
+		dir := start.AddDate(0, 0, i/3).Format(time.DateOnly)
+		file := fmt.Sprintf("%s/%02d-post-%d.md", dir, i%3+1, i)
+		// The first post links to itself
+		if previous == "" {
+			previous = filepath.Join(config.PostsRoot, file)
+		}
+		files[file] = fmt.Sprintf(
+			"# Post %d\n\nSome *text* with `code` and a [link](https://example.com/%d).\n\n"+
+				"```go\nfunc main() {\n\tfmt.Println(%d)\n}\n```\n\n"+
+				"- One\n- Two\n\nSee the [previous post](/%s).\n",
+			i, i, i, previous,
+		)
+		previous = filepath.Join(config.PostsRoot, file)
+	}
+	return files
+}
+
+func newTestMd(ps posts) func() goldmark.Markdown {
+	return func() goldmark.Markdown {
+		return goldmark.New(
+			goldmark.WithExtensions(
+				NewLinkRewriter(config.MainUrl, config.RawUrl, ps),
+				extension.GFM,
+				highlighting.NewHighlighting(highlighting.WithFormatOptions(html.WithClasses(true))),
+			),
+		)
+	}
+}
+
+// withProcs runs fn with GOMAXPROCS set to procs, so the worker pool is used
+// even in machines with a single CPU
+func withProcs(procs int, fn func()) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+	fn()
+}
+
+func TestParallelMapOrder(t *testing.T) {
+	items := make([]int, 1000)
+	for i := range items {
+		items[i] = i
+	}
+
+	var got []int
+	withProcs(8, func() {
+		got = parallelMap(items, func() func(int) int {
+			return func(i int) int {
+				// Make the later items finish first
+				time.Sleep(time.Duration(len(items)-i) * time.Microsecond)
+				return i * 2
+			}
+		})
+	})
+
+	for i, r := range got {
+		if r != i*2 {
+			t.Fatalf("got result: %d at index: %d, want: %d", r, i, i*2)
+		}
+	}
+}
+
+func TestGrabPostsAndRenderAllOrder(t *testing.T) {
+	setupPosts(t, testCorpus(100))
+
+	var ps, rendered posts
+	withProcs(8, func() {
+		var err error
+		ps, err = grabPosts(config.PostsRoot, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered, err = renderAll(ps, "test", newTestMd(ps))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Loading sequentially, the posts are in the file order since each
+	// directory is a later date
+	var files []string
+	for file := range testCorpus(100) {
+		files = append(files, filepath.Join(config.PostsRoot, file))
+	}
+	slices.Sort(files)
+	if got := slices.Collect(ps.Keys()); !slices.Equal(got, files) {
+		t.Errorf("got loaded posts in order: %v, want: %v", got, files)
+	}
+
+	// Rendering sequentially with a single goldmark instance
+	if got, want := slices.Collect(rendered.Keys()), slices.Collect(ps.Keys()); !slices.Equal(got, want) {
+		t.Errorf("got rendered posts in order: %v, want: %v", got, want)
+	}
+	md := newTestMd(ps)()
+	for path, post := range ps.AllFromFront() {
+		var buf bytes.Buffer
+		err := md.Convert(post.contents, &buf, parser.WithContext(postContext(post)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := rendered.Get(path); !bytes.Equal(got.contents, buf.Bytes()) {
+			t.Errorf("got for post: %s:\n%s\nwant:\n%s", path, got.contents, buf.Bytes())
+		}
+	}
+}
+
+// benchmarkProcs runs the benchmark with a single worker and with one per CPU,
+// to compare the sequential and parallel versions
+func benchmarkProcs(b *testing.B, fn func(b *testing.B)) {
+	for _, procs := range slices.Compact([]int{1, runtime.NumCPU()}) {
+		b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+			withProcs(procs, func() { fn(b) })
+		})
+	}
+}
+
+func BenchmarkGrabPosts(b *testing.B) {
+	setupPosts(b, testCorpus(benchmarkPosts))
+	benchmarkProcs(b, func(b *testing.B) {
+		for range b.N {
+			_, err := grabPosts(config.PostsRoot, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRenderAll(b *testing.B) {
+	setupPosts(b, testCorpus(benchmarkPosts))
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// The render cache is disabled in TestMain, so each iteration renders
+	// every post
+	benchmarkProcs(b, func(b *testing.B) {
+		for range b.N {
+			_, err := renderAll(ps, "benchmark", newTestMd(ps))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
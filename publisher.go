@@ -0,0 +1,436 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	pruneReport    = "report"
+	pruneUnpublish = "unpublish"
+	pruneDelete    = "delete"
+)
+
+var errPostNotFound = errors.New("post not found")
+
+// remotePost is a post as returned by a Publisher
+type remotePost struct {
+	slug        string
+	title       string
+	body        string
+	publishedAt string
+}
+
+// Publisher is a backend where the posts can be published to
+type Publisher interface {
+	// Name identifies the publisher, e.g.: in -target flag
+	Name() string
+	// Prepare renders the posts in the format expected by the publisher
+	Prepare(ps posts) (posts, error)
+	// PublishedAt returns the publish date of p as sent to the publisher,
+	// so changes in it can be detected (e.g.: only the time changed)
+	PublishedAt(p post) string
+	// List returns all posts in the publisher
+	List(ctx context.Context) ([]remotePost, error)
+	// Get returns the post with slug, or errPostNotFound if it doesn't
+	// exist
+	Get(ctx context.Context, slug string) (remotePost, error)
+	// Create creates a new post, returning the slug generated by the
+	// publisher
+	Create(ctx context.Context, p post) (string, error)
+	// Update updates the post with slug, including its slug
+	Update(ctx context.Context, slug string, p post) error
+	// Delete deletes the post with slug
+	Delete(ctx context.Context, slug string) error
+}
+
+// Unpublisher is implemented by publishers that support turning posts into
+// drafts
+type Unpublisher interface {
+	Unpublish(ctx context.Context, slug string) error
+}
+
+var publishers = map[string]func() Publisher{
+	"mataroa":     func() Publisher { return &mataroaPublisher{} },
+	"writefreely": func() Publisher { return newWriteFreelyPublisher() },
+}
+
+func newPublisher(target string) (Publisher, error) {
+	newPub, ok := publishers[target]
+	if !ok {
+		var targets []string
+		for t := range publishers {
+			targets = append(targets, t)
+		}
+		sort.Strings(targets)
+		return nil, fmt.Errorf("unknown target: %s, valid ones: %v", target, targets)
+	}
+	return newPub(), nil
+}
+
+// publishState maps each post slug to the hash of the last published
+// version of it, so we only send new or changed posts
+type publishState map[string]string
+
+func publishStateFile(pub Publisher) string {
+	return fmt.Sprintf(".%s-state.json", pub.Name())
+}
+
+func loadPublishState(file string) (publishState, error) {
+	state := publishState{}
+	raw, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("could not read publish state: %w", err)
+	}
+	err = json.Unmarshal(raw, &state)
+	if err != nil {
+		return state, fmt.Errorf("publish state JSON unmarshal error: %w", err)
+	}
+	return state, nil
+}
+
+func (s publishState) save(file string) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("publish state JSON marshal error: %w", err)
+	}
+	return os.WriteFile(file, append(raw, '\n'), 0o644)
+}
+
+// publishHash returns the hash of the post fields that are published to pub
+func publishHash(pub Publisher, p post) (string, error) {
+	raw, err := json.Marshal(struct {
+		Title       string `json:"title"`
+		Slug        string `json:"slug"`
+		Body        string `json:"body"`
+		PublishedAt string `json:"published_at"`
+	}{
+		Title:       p.title,
+		Slug:        p.slug,
+		Body:        string(p.contents),
+		PublishedAt: pub.PublishedAt(p),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(raw)), nil
+}
+
+// renderPosts converts the posts contents to HTML, with links pointing to
+// blogUrl. Since most publishers have no redirects, we also create a stub post
+// for each alias pointing to the current one
+func renderPosts(ps posts, blogUrl string) (posts, error) {
+	renderer := fmt.Sprintf("publisher gfm highlighting=classes blog=%s raw=%s", blogUrl, config.RawUrl)
+	rendered, err := renderAll(ps, renderer, func() goldmark.Markdown {
+		return goldmark.New(
+			goldmark.WithExtensions(
+				NewLinkRewriter(blogUrl, config.RawUrl, ps),
+				extension.GFM,
+				highlighting.NewHighlighting(
+					// No style since we are reusing the style
+					// from the publisher
+					highlighting.WithFormatOptions(html.WithClasses(true)),
+				),
+			),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	preparedPosts := orderedmap.NewOrderedMap[path, post]()
+	for path, post := range rendered.AllFromFront() {
+		preparedPosts.Set(path, post)
+
+		postUrl, err := url.JoinPath(blogUrl, post.slug)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range post.aliases {
+			stub := post
+			stub.slug = alias
+			stub.aliases = nil
+			stub.contents = []byte(fmt.Sprintf(
+				`<p>This post was moved to <a href="%s">%s</a>.</p>`,
+				postUrl,
+				template.HTMLEscapeString(post.title),
+			))
+			preparedPosts.Set(path+"#"+alias, stub)
+		}
+	}
+	return preparedPosts, nil
+}
+
+// publishPost creates or updates a post in the publisher
+func publishPost(ctx context.Context, pub Publisher, post post) error {
+	_, err := pub.Get(ctx, post.slug)
+	if err == nil {
+		err = pub.Update(ctx, post.slug, post)
+		if err == nil {
+			log.Printf("[UPDATED] target=%s post=%s\n", pub.Name(), post.slug)
+		}
+		return err
+	} else if !errors.Is(err, errPostNotFound) {
+		return err
+	}
+
+	slug, err := pub.Create(ctx, post)
+	if err != nil {
+		return err
+	}
+	log.Printf("[NEW] target=%s post=%s\n", pub.Name(), slug)
+
+	if slug != post.slug {
+		log.Printf(
+			"[INFO] Updating slug since they're different, %s slug: %s, generated one: %s",
+			pub.Name(),
+			slug,
+			post.slug,
+		)
+		err = pub.Update(ctx, slug, post)
+		if err == nil {
+			log.Printf("[UPDATED] target=%s post=%s\n", pub.Name(), post.slug)
+		}
+		return err
+	}
+	return nil
+}
+
+// publishPosts creates or updates all new or changed posts since the last run,
+// unless force is true
+func publishPosts(ctx context.Context, pub Publisher, ps posts, force bool) error {
+	stateFile := publishStateFile(pub)
+	state, err := loadPublishState(stateFile)
+	if err != nil {
+		return err
+	}
+
+	prepared, err := pub.Prepare(ps)
+	if err != nil {
+		return err
+	}
+
+	skipped := 0
+	failed := map[string]error{}
+	for post := range prepared.Values() {
+		hash, err := publishHash(pub, post)
+		if err != nil {
+			return err
+		}
+		if !force && state[post.slug] == hash {
+			skipped++
+			continue
+		}
+
+		// Keep going so one broken post doesn't block the others
+		err = publishPost(ctx, pub, post)
+		if err != nil {
+			log.Printf("[FAILED] target=%s post=%s: %v\n", pub.Name(), post.slug, err)
+			failed[post.slug] = err
+			// No reason to continue if we got cancelled
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		// Save after each post, so a failure doesn't lose what was
+		// already published
+		state[post.slug] = hash
+		err = state.save(stateFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[INFO] Skipped %d unchanged posts\n", skipped)
+	if len(failed) > 0 {
+		for slug, err := range failed {
+			log.Printf("[SUMMARY] failed post=%s: %v\n", slug, err)
+		}
+		return fmt.Errorf("failed to publish %d posts", len(failed))
+	}
+	return nil
+}
+
+// diffText returns a text representation of a post, used to diff the remote
+// and local versions
+func diffText(p remotePost) string {
+	return fmt.Sprintf(
+		"title: %s\nslug: %s\npublished_at: %s\n\n%s",
+		p.title,
+		p.slug,
+		p.publishedAt,
+		p.body,
+	)
+}
+
+// dryRunPublish shows what publishPosts would do, without doing any write requests
+func dryRunPublish(ctx context.Context, pub Publisher, ps posts) error {
+	prepared, err := pub.Prepare(ps)
+	if err != nil {
+		return err
+	}
+
+	var created, updated, unchanged []string
+	for post := range prepared.Values() {
+		local := diffText(remotePost{
+			slug:        post.slug,
+			title:       post.title,
+			body:        string(post.contents),
+			publishedAt: pub.PublishedAt(post),
+		})
+
+		var remote string
+		p, err := pub.Get(ctx, post.slug)
+		if err == nil {
+			remote = diffText(p)
+		} else if !errors.Is(err, errPostNotFound) {
+			return err
+		}
+
+		diff := unifiedDiff("remote/"+post.slug, "local/"+post.slug, remote, local)
+		switch {
+		case remote == "":
+			created = append(created, post.slug)
+		case diff != "":
+			updated = append(updated, post.slug)
+		default:
+			unchanged = append(unchanged, post.slug)
+			continue
+		}
+		fmt.Print(diff)
+	}
+
+	log.Printf("[DRY-RUN] Would create %d posts: %v\n", len(created), created)
+	log.Printf("[DRY-RUN] Would update %d posts: %v\n", len(updated), updated)
+	log.Printf("[DRY-RUN] Would leave %d posts untouched\n", len(unchanged))
+	return nil
+}
+
+// confirm asks the user to type "yes" in stdin before continuing
+func confirm(prompt string) bool {
+	fmt.Fprintf(os.Stderr, "%s Type 'yes' to continue: ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// validatePruneAction returns an error if action is invalid or not supported
+// by pub, so we can fail before doing any request
+func validatePruneAction(pub Publisher, action string) error {
+	actions := []string{pruneReport, pruneUnpublish, pruneDelete}
+	if !slices.Contains(actions, action) {
+		return fmt.Errorf("invalid prune action: %s, valid ones: %v", action, actions)
+	}
+	if _, ok := pub.(Unpublisher); action == pruneUnpublish && !ok {
+		return fmt.Errorf("target %s does not support unpublishing posts", pub.Name())
+	}
+	return nil
+}
+
+// prunePosts finds posts in the publisher that don't exist locally anymore (e.g.:
+// they were deleted or renamed), and report, unpublish or delete them
+// depending on action. Unless yes is true, destructive actions need to be
+// confirmed by the user
+func prunePosts(ctx context.Context, pub Publisher, ps posts, action string, yes bool) error {
+	err := validatePruneAction(pub, action)
+	if err != nil {
+		return err
+	}
+	unpub, _ := pub.(Unpublisher)
+
+	// Use the prepared posts, since publishers may add extra posts (e.g.:
+	// redirects)
+	prepared, err := pub.Prepare(ps)
+	if err != nil {
+		return err
+	}
+	local := map[string]bool{}
+	for post := range prepared.Values() {
+		local[post.slug] = true
+	}
+
+	remote, err := pub.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var orphans []string
+	for _, p := range remote {
+		if !local[p.slug] {
+			orphans = append(orphans, p.slug)
+			log.Printf("[ORPHAN]: %s (published_at=%s)\n", p.slug, p.publishedAt)
+		}
+	}
+	log.Printf("[INFO] Found %d posts in %s without a local counterpart\n", len(orphans), pub.Name())
+
+	if action == pruneReport || len(orphans) == 0 {
+		return nil
+	}
+	if !yes && !confirm(fmt.Sprintf("This will %s %d posts.", action, len(orphans))) {
+		return errAborted
+	}
+
+	stateFile := publishStateFile(pub)
+	state, err := loadPublishState(stateFile)
+	if err != nil {
+		return err
+	}
+	for _, slug := range orphans {
+		if action == pruneDelete {
+			err = pub.Delete(ctx, slug)
+		} else {
+			err = unpub.Unpublish(ctx, slug)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("[%s] target=%s post=%s\n", strings.ToUpper(action), pub.Name(), slug)
+
+		// Make sure that the post is published again if it comes back
+		delete(state, slug)
+		err = state.save(stateFile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
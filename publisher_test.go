@@ -0,0 +1,110 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPostsAliasStub(t *testing.T) {
+	setupPosts(t, aliasPosts)
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := renderPosts(ps, "https://example.com/blog/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each post plus the stub for the moved one
+	if rendered.Len() != 3 {
+		t.Fatalf("got %d posts, want 3", rendered.Len())
+	}
+	stub, ok := rendered.Get(filepath.Join(config.PostsRoot, "2024-01-01/01-old-title.md") + "#old-title")
+	if !ok {
+		t.Fatal("stub for alias not found")
+	}
+	if stub.slug != "old-title" || len(stub.aliases) != 0 {
+		t.Errorf("got slug: %s, aliases: %v, want slug: old-title, no aliases", stub.slug, stub.aliases)
+	}
+	want := `<a href="https://example.com/blog/new-title">New title</a>`
+	if !strings.Contains(string(stub.contents), want) {
+		t.Errorf("stub doesn't contain: %s, got: %s", want, stub.contents)
+	}
+}
+
+func TestPrunePostsAborted(t *testing.T) {
+	setupPosts(t, aliasPosts)
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeWriteFreely{posts: []writeFreelyPost{{Id: "abc", Slug: "orphan"}}}
+	w := newTestWriteFreely(t, f)
+
+	// Answer anything but "yes" in the confirmation
+	stdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = stdin })
+	r, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.WriteString("no\n")
+	pw.Close()
+	os.Stdin = r
+
+	err = prunePosts(context.Background(), w, ps, pruneDelete, false)
+	if !errors.Is(err, errAborted) || exitCode(err) != exitInterrupted {
+		t.Errorf("got error: %v (exit code: %d), want: %v (exit code: %d)", err, exitCode(err), errAborted, exitInterrupted)
+	}
+	if len(f.posts) != 1 {
+		t.Errorf("got %d posts, want the orphan post to be kept", len(f.posts))
+	}
+}
+
+func TestPublishHashDate(t *testing.T) {
+	p := post{title: "Post", slug: "post", date: time.Date(2024, 8, 1, 9, 0, 0, 0, time.UTC)}
+	later := p
+	later.date = later.date.Add(6 * time.Hour)
+
+	for _, tt := range []struct {
+		pub      Publisher
+		wantSame bool
+	}{
+		// Mataroa only receives the date
+		{&mataroaPublisher{}, true},
+		{&writeFreelyPublisher{}, false},
+	} {
+		hash, err := publishHash(tt.pub, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		laterHash, err := publishHash(tt.pub, later)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := hash == laterHash; same != tt.wantSame {
+			t.Errorf("%s: got same hash after changing the time: %v, want: %v", tt.pub.Name(), same, tt.wantSame)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/readme.md.tmpl
+var defaultReadmeTemplate string
+
+// readmePost is a post as seen by the README template
+type readmePost struct {
+	Title     string
+	Path      string
+	Slug      string
+	Date      time.Time
+	Year      int
+	Tags      []string
+	Aliases   []string
+	WordCount int
+}
+
+type readmeTag struct {
+	Name    string
+	Count   int
+	FeedUrl string
+	Posts   []readmePost
+}
+
+type readmeMonth struct {
+	Month time.Month
+	Count int
+	Posts []readmePost
+}
+
+type readmeYear struct {
+	Year  int
+	Count int
+	// Older years may be collapsed to keep the README short
+	Collapsed bool
+	Months    []readmeMonth
+}
+
+type readmeRedirect struct {
+	Alias string
+	Post  readmePost
+}
+
+// readmeData is what is available inside the README template. Posts are
+// sorted from the newest to the oldest, and Years has the same posts grouped
+// by year and month
+type readmeData struct {
+	// From config, e.g.: for forks
+	BlogUrl     string
+	FeedUrl     string
+	RssBadge    string
+	GroupByDate bool
+	Posts       []readmePost
+	Years       []readmeYear
+	Tags        []readmeTag
+	Redirects   []readmeRedirect
+}
+
+func toReadmePosts(ps posts) []readmePost {
+	var rps []readmePost
+	for path, post := range ps.AllFromBack() {
+		rps = append(rps, readmePost{
+			Title:     post.title,
+			Path:      path,
+			Slug:      post.slug,
+			Date:      post.date,
+			Year:      post.date.Year(),
+			Tags:      post.tags,
+			Aliases:   post.aliases,
+			WordCount: len(strings.Fields(string(post.contents))),
+		})
+	}
+	return rps
+}
+
+// groupByDate groups the posts by year and month, keeping their order. Only
+// the first expandedYears are expanded, unless it is 0
+func groupByDate(rps []readmePost, expandedYears int) []readmeYear {
+	var years []readmeYear
+	for _, post := range rps {
+		if len(years) == 0 || years[len(years)-1].Year != post.Year {
+			years = append(years, readmeYear{
+				Year:      post.Year,
+				Collapsed: expandedYears > 0 && len(years) >= expandedYears,
+			})
+		}
+		year := &years[len(years)-1]
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != post.Date.Month() {
+			year.Months = append(year.Months, readmeMonth{Month: post.Date.Month()})
+		}
+		month := &year.Months[len(year.Months)-1]
+		month.Posts = append(month.Posts, post)
+		month.Count++
+		year.Count++
+	}
+	return years
+}
+
+func genReadmeData(ps posts) (readmeData, error) {
+	data := readmeData{
+		BlogUrl:     config.BlogUrl,
+		FeedUrl:     config.FeedUrl,
+		RssBadge:    rssBadge,
+		GroupByDate: config.ReadmeGroupByDate,
+		Posts:       toReadmePosts(ps),
+	}
+	data.Years = groupByDate(data.Posts, config.ReadmeExpandedYears)
+	for _, tc := range countTags(ps) {
+		u, err := feedUrl(rssFile, tc.tag)
+		if err != nil {
+			return data, err
+		}
+		data.Tags = append(data.Tags, readmeTag{
+			Name:    tc.tag,
+			Count:   tc.count,
+			FeedUrl: u,
+			Posts:   toReadmePosts(filterByTag(ps, tc.tag)),
+		})
+	}
+	for _, post := range data.Posts {
+		for _, alias := range post.Aliases {
+			data.Redirects = append(data.Redirects, readmeRedirect{alias, post})
+		}
+	}
+	return data, nil
+}
+
+// loadReadmeTemplate parses the template in file, or the embedded one if file
+// is empty
+func loadReadmeTemplate(file string) (*template.Template, error) {
+	if file == "" {
+		return template.New("readme").Parse(defaultReadmeTemplate)
+	}
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read README template: %w", err)
+	}
+	return template.New(filepath.Base(file)).Parse(string(raw))
+}
+
+func genReadme(ps posts) (string, error) {
+	tmpl, err := loadReadmeTemplate(config.ReadmeTemplate)
+	if err != nil {
+		return "", err
+	}
+	data, err := genReadmeData(ps)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render README template: %w", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenReadmeUrls(t *testing.T) {
+	setupPosts(t, map[string]string{"2024-01-01/01-hello.md": "# Hello\n\nHi.\n"})
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := config
+	t.Cleanup(func() { config = c })
+	config.BlogUrl = "https://fork.example/"
+	config.FeedUrl = "https://fork.example/rss.xml"
+
+	readme, err := genReadme(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Mirror of my blog in https://fork.example/.",
+		"(https://fork.example/rss.xml)",
+	} {
+		if !strings.Contains(readme, want) {
+			t.Errorf("README doesn't contain: %s, got:\n%s", want, readme)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+// Bump this if the output changes for the same input (e.g.: a change in
+// linkRewriter). Updates of the rendering dependencies are detected
+// automatically
+const renderCacheVersion = 2
+
+// renderCacheDir is where the rendered posts are cached, empty disables the
+// cache (e.g.: with -no-cache)
+var renderCacheDir = ".cache/render"
+
+// renderDepsKey stores the renderDeps of the post being converted in the
+// parser context, so linkRewriter can record them
+var renderDepsKey = parser.NewContextKey()
+
+// renderDeps is what the output of a conversion depends on besides the
+// contents of the post and the renderer configuration, e.g.: a link is only
+// rewritten if the file it points to exists
+type renderDeps struct {
+	// Whether each file existed
+	Files map[string]bool `json:"files,omitempty"`
+	// The slug of the post for each link, empty if not found
+	Slugs map[path]string `json:"slugs,omitempty"`
+}
+
+func newRenderDeps() *renderDeps {
+	return &renderDeps{Files: map[string]bool{}, Slugs: map[path]string{}}
+}
+
+// exists returns true if file exists, recording it. Safe to call with a nil
+// renderDeps
+func (d *renderDeps) exists(file string) bool {
+	_, err := os.Stat(file)
+	if d != nil {
+		d.Files[file] = err == nil
+	}
+	return err == nil
+}
+
+// lookup returns the post for link in ps, recording its slug. Safe to call
+// with a nil renderDeps
+func (d *renderDeps) lookup(ps posts, link path) (post, bool) {
+	p, ok := ps.Get(link)
+	if d != nil {
+		d.Slugs[link] = p.slug
+	}
+	return p, ok
+}
+
+// valid returns true if the dependencies are still the same, with links
+// resolved in ps
+func (d *renderDeps) valid(ps posts) bool {
+	for file, existed := range d.Files {
+		if _, err := os.Stat(file); (err == nil) != existed {
+			return false
+		}
+	}
+	for link, slug := range d.Slugs {
+		var p post
+		if ps != nil {
+			p, _ = ps.Get(link)
+		}
+		if p.slug != slug {
+			return false
+		}
+	}
+	return true
+}
+
+type renderCacheEntry struct {
+	Deps     *renderDeps `json:"deps"`
+	Contents []byte      `json:"contents"`
+}
+
+// rendererVersions returns the versions of the rendering dependencies, since
+// the output may change when updating e.g.: goldmark or chroma
+var rendererVersions = sync.OnceValue(func() string {
+	var versions []string
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if strings.Contains(dep.Path, "goldmark") || strings.Contains(dep.Path, "chroma") {
+				versions = append(versions, dep.Path+"@"+dep.Version)
+			}
+		}
+	}
+	return strings.Join(versions, ",")
+})
+
+// renderCacheKey returns the key for contents converted by renderer, a
+// description of its configuration (e.g.: extensions, chroma style and link
+// prefix)
+func renderCacheKey(renderer string, contents []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n%s\n%s\n", renderCacheVersion, rendererVersions(), renderer)
+	h.Write(contents)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func renderCacheFile(key string) string {
+	return filepath.Join(renderCacheDir, key[:2], key+".json")
+}
+
+// getRenderCache returns the cached output for key, if it exists and its
+// dependencies didn't change
+func getRenderCache(key string, ps posts) ([]byte, bool) {
+	if renderCacheDir == "" {
+		return nil, false
+	}
+	raw, err := os.ReadFile(renderCacheFile(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry renderCacheEntry
+	// Ignore broken entries, they will be overwritten
+	if json.Unmarshal(raw, &entry) != nil || entry.Deps == nil || !entry.Deps.valid(ps) {
+		return nil, false
+	}
+	return entry.Contents, true
+}
+
+func setRenderCache(key string, contents []byte, deps *renderDeps) error {
+	if renderCacheDir == "" {
+		return nil
+	}
+	raw, err := json.Marshal(renderCacheEntry{Deps: deps, Contents: contents})
+	if err != nil {
+		return fmt.Errorf("render cache JSON marshal error: %w", err)
+	}
+	file := renderCacheFile(key)
+	err = os.MkdirAll(filepath.Dir(file), 0o755)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so other processes (e.g.: make -j)
+	// never read a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(raw)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,164 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renderCachePost links to another post and images, so its output depends on
+// them
+const renderCachePost = "posts/2024-01-01/01-a.md"
+
+var renderCachePosts = map[string]string{
+	"2024-01-01/01-a.md": "# A\n\n[B](/posts/2024-01-02/01-b.md)\n\n" +
+		"[![Linked](/posts/2024-01-01/linked.jpg)](/posts/2024-01-01/linked.jpg)\n",
+	"2024-01-02/01-b.md": "# B\n\nLinked.\n",
+}
+
+func writeTestFile(t *testing.T, file, contents string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(file), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(file, []byte(contents), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustJoinPath(t *testing.T, base string, elem ...string) string {
+	t.Helper()
+	u, err := url.JoinPath(base, elem...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRenderAllCache(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		files  map[string]string
+		change func(t *testing.T)
+		want   string // in the output after the change
+	}{
+		{
+			name:   "unchanged",
+			change: nil,
+			want:   `href="/posts/2024-01-01/linked.jpg"`,
+		},
+		{
+			name: "file appearing",
+			change: func(t *testing.T) {
+				writeTestFile(t, "posts/2024-01-01/linked.jpg", "original")
+			},
+			want: `href="` + mustJoinPath(t, config.RawUrl, "posts/2024-01-01/linked.jpg") + `"`,
+		},
+		{
+			name:  "file disappearing",
+			files: map[string]string{"2024-01-01/linked.jpg": "original"},
+			change: func(t *testing.T) {
+				err := os.Remove("posts/2024-01-01/linked.jpg")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: `href="/posts/2024-01-01/linked.jpg"`,
+		},
+		{
+			name: "linked post changing slug",
+			change: func(t *testing.T) {
+				writeTestFile(t, "posts/2024-01-02/01-b.md", "---\nslug: new-b\n---\n# B\n\nLinked.\n")
+			},
+			want: `href="` + mustJoinPath(t, config.MainUrl, "new-b") + `"`,
+		},
+		{
+			name:  "optimized images being generated",
+			files: map[string]string{"2024-01-01/linked.jpg": "original"},
+			change: func(t *testing.T) {
+				writeTestFile(t, optimizedImagePath("posts/2024-01-01/linked.jpg"), "optimized")
+				writeTestFile(t, thumbnailImagePath("posts/2024-01-01/linked.jpg"), "optimized")
+			},
+			want: `src="` + mustJoinPath(t, config.RawUrl, "optimized/posts/2024-01-01/linked.thumb.jpg") + `"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			for name, contents := range renderCachePosts {
+				files[name] = contents
+			}
+			for name, contents := range tt.files {
+				files[name] = contents
+			}
+			setupPosts(t, files)
+			defer func(dir string) { renderCacheDir = dir }(renderCacheDir)
+			renderCacheDir = t.TempDir()
+
+			render := func() (posts, string) {
+				t.Helper()
+				ps, err := grabPosts(config.PostsRoot, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rendered, err := renderAll(ps, "test", newTestMd(ps))
+				if err != nil {
+					t.Fatal(err)
+				}
+				p, _ := rendered.Get(renderCachePost)
+				return ps, string(p.contents)
+			}
+			cached := func(ps posts) bool {
+				p, _ := ps.Get(renderCachePost)
+				_, ok := getRenderCache(renderCacheKey("test", p.contents), ps)
+				return ok
+			}
+
+			ps, before := render()
+			if !cached(ps) {
+				t.Fatal("got no cache entry after rendering")
+			}
+
+			if tt.change == nil {
+				_, after := render()
+				if after != before {
+					t.Errorf("got different output from cache:\n%s\nwant:\n%s", after, before)
+				}
+			} else {
+				tt.change(t)
+				ps, err := grabPosts(config.PostsRoot, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cached(ps) {
+					t.Fatal("got cache entry after the dependency changed")
+				}
+				if strings.Contains(before, tt.want) {
+					t.Fatalf("output already contains: %s, got:\n%s", tt.want, before)
+				}
+			}
+
+			_, after := render()
+			if !strings.Contains(after, tt.want) {
+				t.Errorf("output doesn't contain: %s, got:\n%s", tt.want, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// humanDuration formats d with at most two units, e.g.: 2d3h, 5h10m
+func humanDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// genSchedule lists the posts that will be published after now, sorted by
+// when they go live. Drafts are not listed, since they're never published
+func genSchedule(ps posts, now time.Time) string {
+	var scheduled []post
+	for post := range ps.Values() {
+		if !post.draft && post.date.After(now) {
+			scheduled = append(scheduled, post)
+		}
+	}
+	slices.SortStableFunc(scheduled, func(a, b post) int { return a.date.Compare(b.date) })
+
+	var sb strings.Builder
+	for _, post := range scheduled {
+		fmt.Fprintf(
+			&sb,
+			"%s\tin %s\t%s\t%s\n",
+			post.date.Format(time.RFC3339),
+			humanDuration(post.date.Sub(now)),
+			post.file,
+			post.title,
+		)
+	}
+	return sb.String()
+}
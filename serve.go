@@ -0,0 +1,243 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const watchInterval = 500 * time.Millisecond
+
+// preview serves the blog locally, reloading the posts and any connected
+// browser once a Markdown file changes
+type preview struct {
+	root   string
+	drafts bool
+
+	mu       sync.RWMutex
+	site     *site
+	watchers map[chan struct{}]struct{}
+}
+
+func (p *preview) load() error {
+	ps, err := grabPosts(p.root, p.drafts)
+	// Serve the valid posts, so we can fix the broken ones without
+	// restarting the server
+	if err != nil && !onlyPostErrors(err) {
+		return err
+	}
+	for _, err := range flattenErrors(err) {
+		log.Printf("[WARN]: ignoring invalid post: %v\n", err)
+	}
+
+	s := newSite(ps)
+	s.liveReload = true
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.site = s
+	return nil
+}
+
+func (p *preview) current() *site {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.site
+}
+
+// notify wakes up every connected browser so it can reload the page
+func (p *preview) notify() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for w := range p.watchers {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *preview) subscribe() chan struct{} {
+	w := make(chan struct{}, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.watchers[w] = struct{}{}
+	return w
+}
+
+func (p *preview) unsubscribe(w chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.watchers, w)
+}
+
+// snapshot returns the modification time of each Markdown file in root, so
+// we can detect changes without depending on OS specific watch APIs
+func snapshot(root string) (map[string]time.Time, error) {
+	mtimes := map[string]time.Time{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(d.Name()) != ".md" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mtimes[path] = info.ModTime()
+		return nil
+	})
+	return mtimes, err
+}
+
+func changed(old, new map[string]time.Time) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for path, mtime := range new {
+		if !old[path].Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *preview) watch() {
+	last, err := snapshot(p.root)
+	if err != nil {
+		log.Printf("[WARN]: could not watch directory: %s, error: %v\n", p.root, err)
+	}
+
+	for range time.Tick(watchInterval) {
+		current, err := snapshot(p.root)
+		if err != nil {
+			log.Printf("[WARN]: could not watch directory: %s, error: %v\n", p.root, err)
+			continue
+		}
+		if !changed(last, current) {
+			continue
+		}
+		last = current
+
+		// Keep serving the old posts if the new ones are broken, so we
+		// can fix them without restarting the server
+		err = p.load()
+		if err != nil {
+			log.Printf("[WARN]: could not reload posts, error: %v\n", err)
+			continue
+		}
+		log.Printf("[INFO]: reloaded posts\n")
+		p.notify()
+	}
+}
+
+func (p *preview) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := p.current().renderIndex(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (p *preview) handlePost(w http.ResponseWriter, r *http.Request) {
+	s := p.current()
+	slug := r.PathValue("slug")
+	for post := range s.posts.Values() {
+		if slices.Contains(post.aliases, slug) {
+			http.Redirect(w, r, s.postUrl(post), http.StatusMovedPermanently)
+			return
+		}
+		if post.slug != slug {
+			continue
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := s.renderPost(w, post)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (p *preview) handleCss(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	err := p.current().renderCss(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleReload is a Server-Sent Events endpoint that sends a message every
+// time the posts are reloaded
+func (p *preview) handleReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	watcher := p.subscribe()
+	defer p.unsubscribe(watcher)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-watcher:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func servePreview(addr, root string, drafts bool) error {
+	p := &preview{
+		root:     root,
+		drafts:   drafts,
+		watchers: map[chan struct{}]struct{}{},
+	}
+	err := p.load()
+	if err != nil {
+		return err
+	}
+	go p.watch()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", p.handleIndex)
+	mux.HandleFunc("GET /{slug}/", p.handlePost)
+	mux.HandleFunc("GET /"+siteStylesheet, p.handleCss)
+	mux.HandleFunc("GET "+siteReloadPath, p.handleReload)
+	mux.Handle("GET /"+root+"/", http.FileServer(http.Dir(".")))
+	mux.Handle("GET /"+imagesDir+"/", http.FileServer(http.Dir(".")))
+
+	log.Printf("[INFO]: serving preview in: %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
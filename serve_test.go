@@ -0,0 +1,49 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlePostAliasRedirect(t *testing.T) {
+	setupPosts(t, aliasPosts)
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &preview{site: newSite(ps)}
+
+	for slug, want := range map[string]int{
+		"old-title":  http.StatusMovedPermanently,
+		"new-title":  http.StatusOK,
+		"typo-titel": http.StatusOK,
+		"missing":    http.StatusNotFound,
+	} {
+		req := httptest.NewRequest("GET", "/"+slug+"/", nil)
+		req.SetPathValue("slug", slug)
+		rec := httptest.NewRecorder()
+		p.handlePost(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("slug: %s, got status: %d, want: %d", slug, rec.Code, want)
+		}
+		if want == http.StatusMovedPermanently && rec.Header().Get("Location") != "/new-title/" {
+			t.Errorf("slug: %s, got location: %s, want: /new-title/", slug, rec.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+const (
+	siteBaseUrl    = "/"
+	siteStylesheet = "style.css"
+	siteReloadPath = "/_reload"
+	siteLayout     = `<!DOCTYPE html>
+<html lang="{{ .Lang }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ if .Post }}{{ .Post.Title }} - {{ end }}{{ .Title }}</title>
+<link rel="stylesheet" href="/` + siteStylesheet + `">
+</head>
+<body>
+<header><a href="/">{{ .Title }}</a></header>
+<main>
+{{ if .Post -}}
+<article>
+<h1>{{ .Post.Title }}</h1>
+<p><time datetime="{{ .Post.Date }}">{{ .Post.Date }}</time>{{ range .Post.Tags }} <code>#{{ . }}</code>{{ end }}</p>
+{{ .Post.Contents }}
+</article>
+{{- else -}}
+<h1>Posts</h1>
+<ul>
+{{ range .Posts -}}
+<li><a href="{{ .Url }}">{{ .Title }}</a> - <time datetime="{{ .Date }}">{{ .Date }}</time></li>
+{{ end -}}
+</ul>
+{{- end }}
+</main>
+{{ if .LiveReload -}}
+<script>new EventSource("` + siteReloadPath + `").onmessage = () => location.reload();</script>
+{{ end -}}
+</body>
+</html>
+`
+	siteBaseCss = `body { max-width: 50em; margin: 0 auto; padding: 1em; font-family: sans-serif; line-height: 1.5; }
+img { max-width: 100%; height: auto; }
+pre { padding: 1em; overflow-x: auto; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid; padding: 0.25em 0.5em; }
+`
+	siteRedirectLayout = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<link rel="canonical" href="{{ .Url }}">
+<meta http-equiv="refresh" content="0; url={{ .Url }}">
+</head>
+<body>
+<p>This post was moved to <a href="{{ .Url }}">{{ .Title }}</a>.</p>
+</body>
+</html>
+`
+)
+
+var (
+	siteTemplate         = template.Must(template.New("site").Parse(siteLayout))
+	siteRedirectTemplate = template.Must(template.New("redirect").Parse(siteRedirectLayout))
+)
+
+type sitePost struct {
+	Title    string
+	Url      string
+	Date     string
+	Tags     []string
+	Contents template.HTML
+}
+
+type sitePage struct {
+	Title      string
+	Lang       string
+	Post       *sitePost
+	Posts      []sitePost
+	LiveReload bool
+}
+
+// site renders posts as HTML pages, using the same pipeline as Mataroa
+type site struct {
+	posts      posts
+	md         goldmark.Markdown
+	liveReload bool
+}
+
+func newSite(ps posts) *site {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			NewLinkRewriter(siteBaseUrl, siteBaseUrl, ps),
+			extension.GFM,
+			highlighting.NewHighlighting(
+				// Style is in siteStylesheet
+				highlighting.WithFormatOptions(html.WithClasses(true)),
+			),
+		),
+	)
+	return &site{posts: ps, md: md}
+}
+
+func (s *site) postUrl(p post) string {
+	return siteBaseUrl + p.slug + "/"
+}
+
+func (s *site) renderIndex(w io.Writer) error {
+	page := sitePage{Title: config.Title, Lang: "en", LiveReload: s.liveReload}
+	for _, post := range s.posts.AllFromBack() {
+		page.Posts = append(page.Posts, sitePost{
+			Title: post.title,
+			Url:   s.postUrl(post),
+			Date:  post.date.Format(time.DateOnly),
+		})
+	}
+	return siteTemplate.Execute(w, page)
+}
+
+func (s *site) renderPost(w io.Writer, p post) error {
+	var buf bytes.Buffer
+	err := s.md.Convert(p.contents, &buf, parser.WithContext(postContext(p)))
+	if err != nil {
+		return fmt.Errorf("could not convert post %s: %w", p.slug, err)
+	}
+
+	lang := p.lang
+	if lang == "" {
+		lang = "en"
+	}
+	return siteTemplate.Execute(w, sitePage{
+		Title:      config.Title,
+		Lang:       lang,
+		LiveReload: s.liveReload,
+		Post: &sitePost{
+			Title:    p.title,
+			Url:      s.postUrl(p),
+			Date:     p.date.Format(time.DateOnly),
+			Tags:     p.tags,
+			Contents: template.HTML(buf.String()),
+		},
+	})
+}
+
+// renderRedirect renders a stub page redirecting from an alias to the post
+func (s *site) renderRedirect(w io.Writer, p post) error {
+	return siteRedirectTemplate.Execute(w, sitePost{
+		Title: p.title,
+		Url:   s.postUrl(p),
+	})
+}
+
+func (s *site) renderCss(w io.Writer) error {
+	_, err := io.WriteString(w, siteBaseCss)
+	if err != nil {
+		return err
+	}
+	return html.New(html.WithClasses(true)).WriteCSS(w, styles.Get(config.ChromaStyle))
+}
+
+func writeSiteFile(name string, render func(io.Writer) error) error {
+	err := os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = render(f)
+	if err != nil {
+		return fmt.Errorf("could not render file: %s, error: %w", name, err)
+	}
+	return f.Close()
+}
+
+// copyImages copies the images from root to outDir, keeping the same
+// directory structure so the links stay valid. Images with an optimized
+// version are skipped
+func copyImages(root, outDir string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		// e.g.: the optimized images were not generated
+		if path == root && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !hasAnyExtension(d.Name(), ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp") {
+			return nil
+		}
+		// The posts link to the optimized image instead (see
+		// linkRewriter), so don't publish the original with its metadata
+		if _, err := os.Stat(optimizedImagePath(path)); err == nil {
+			return nil
+		}
+
+		return writeSiteFile(filepath.Join(outDir, path), func(w io.Writer) error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(w, f)
+			return err
+		})
+	})
+}
+
+func genSite(ps posts, root, outDir string) error {
+	s := newSite(ps)
+
+	err := writeSiteFile(filepath.Join(outDir, "index.html"), s.renderIndex)
+	if err != nil {
+		return err
+	}
+	err = writeSiteFile(filepath.Join(outDir, siteStylesheet), s.renderCss)
+	if err != nil {
+		return err
+	}
+
+	for path, post := range ps.AllFromFront() {
+		err = writeSiteFile(
+			filepath.Join(outDir, post.slug, "index.html"),
+			func(w io.Writer) error { return s.renderPost(w, post) },
+		)
+		if err != nil {
+			return fmt.Errorf("something went wrong with file: %s, error: %w", path, err)
+		}
+
+		// Keep the old URLs working
+		for _, alias := range post.aliases {
+			err = writeSiteFile(
+				filepath.Join(outDir, alias, "index.html"),
+				func(w io.Writer) error { return s.renderRedirect(w, post) },
+			)
+			if err != nil {
+				return fmt.Errorf("something went wrong with file: %s, error: %w", path, err)
+			}
+		}
+	}
+
+	err = copyImages(root, outDir)
+	if err != nil {
+		return err
+	}
+	err = copyImages(imagesDir, outDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO]: generated %d posts in: %s\n", ps.Len(), outDir)
+	return nil
+}
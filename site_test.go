@@ -0,0 +1,118 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenSiteAliasRedirect(t *testing.T) {
+	setupPosts(t, aliasPosts)
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	err = genSite(ps, config.PostsRoot, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(outDir, "old-title", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<link rel="canonical" href="/new-title/">`,
+		`<meta http-equiv="refresh" content="0; url=/new-title/">`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("redirect stub doesn't contain: %s, got:\n%s", want, raw)
+		}
+	}
+	// No redirect if the alias is the slug itself
+	_, err = os.Stat(filepath.Join(outDir, "typo-titel", "index.html"))
+	if err != nil {
+		t.Errorf("expected the post in its own slug, error: %v", err)
+	}
+}
+
+func TestGenSiteLinkedImage(t *testing.T) {
+	setupPosts(t, map[string]string{
+		"2024-01-01/01-photos.md": "# Photos\n\n" +
+			"[![Big](/posts/2024-01-01/big.jpg)](/posts/2024-01-01/big.jpg)\n\n" +
+			"[![Small](/posts/2024-01-01/small.jpg)](/posts/2024-01-01/small.jpg)\n",
+		"2024-01-01/big.jpg":   "original",
+		"2024-01-01/small.jpg": "original",
+	})
+	for _, file := range []string{
+		optimizedImagePath("posts/2024-01-01/big.jpg"),
+		thumbnailImagePath("posts/2024-01-01/big.jpg"),
+	} {
+		err := os.MkdirAll(filepath.Dir(file), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(file, []byte("optimized"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ps, err := grabPosts(config.PostsRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	err = genSite(ps, config.PostsRoot, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(outDir, "photos", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// Full size optimized image, with its thumbnail inline
+		`<a href="/optimized/posts/2024-01-01/big.jpg"><img src="/optimized/posts/2024-01-01/big.thumb.jpg" alt="Big"`,
+		// No optimized image, so the original is used
+		`<a href="/posts/2024-01-01/small.jpg"><img src="/posts/2024-01-01/small.jpg" alt="Small"`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("post doesn't contain: %s, got:\n%s", want, raw)
+		}
+	}
+
+	for _, tt := range []struct {
+		file string
+		want bool
+	}{
+		{"optimized/posts/2024-01-01/big.jpg", true},
+		{"optimized/posts/2024-01-01/big.thumb.jpg", true},
+		{"posts/2024-01-01/small.jpg", true},
+		// Replaced by the optimized image
+		{"posts/2024-01-01/big.jpg", false},
+	} {
+		_, err := os.Stat(filepath.Join(outDir, tt.file))
+		if got := err == nil; got != tt.want {
+			t.Errorf("got %s copied: %v, want: %v", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+type tagCount struct {
+	tag   string
+	count int
+}
+
+// normalizeTags slugifies the tags, so "NixOS" and "nixos" are the same tag,
+// and remove any duplicates
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, t := range tags {
+		t = getSlug(t)
+		if t != "" && !slices.Contains(normalized, t) {
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized
+}
+
+// countTags returns each tag with its number of posts, sorted by most used
+// first
+func countTags(ps posts) []tagCount {
+	counts := map[string]int{}
+	for post := range ps.Values() {
+		for _, t := range post.tags {
+			counts[t]++
+		}
+	}
+
+	var tcs []tagCount
+	for t, c := range counts {
+		tcs = append(tcs, tagCount{t, c})
+	}
+	slices.SortFunc(tcs, func(a, b tagCount) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.tag, b.tag)
+	})
+	return tcs
+}
+
+// filterByTag returns the posts containing tag, keeping the original order
+func filterByTag(ps posts, tag string) posts {
+	filtered := orderedmap.NewOrderedMap[path, post]()
+	for path, post := range ps.AllFromFront() {
+		if slices.Contains(post.tags, tag) {
+			filtered.Set(path, post)
+		}
+	}
+	return filtered
+}
+
+func genTags(ps posts) string {
+	var sb strings.Builder
+	for _, tc := range countTags(ps) {
+		fmt.Fprintf(&sb, "%s\t%d\n", tc.tag, tc.count)
+	}
+	return sb.String()
+}
@@ -0,0 +1,274 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WriteFreely expects this exact format in the created field
+const writeFreelyTimeFormat = "2006-01-02T15:04:05Z"
+
+// https://developers.write.as/docs/api/
+type writeFreelyResponse struct {
+	Code     int             `json:"code"`
+	ErrorMsg string          `json:"error_msg"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type writeFreelyPost struct {
+	Id      string    `json:"id"`
+	Slug    string    `json:"slug"`
+	Title   string    `json:"title"`
+	Body    string    `json:"body"`
+	Created time.Time `json:"created"`
+}
+
+type writeFreelyPostList struct {
+	Posts []writeFreelyPost `json:"posts"`
+}
+
+type writeFreelyPostRequest struct {
+	Title   string `json:"title"`
+	Slug    string `json:"slug,omitempty"`
+	Body    string `json:"body"`
+	Created string `json:"created"`
+	Lang    string `json:"lang,omitempty"`
+}
+
+// writeFreelyPublisher publishes posts to a collection (e.g.: a blog) in a
+// WriteFreely instance, so they can be followed from the fediverse
+type writeFreelyPublisher struct {
+	baseUrl    string
+	collection string
+	token      string
+	client     *retryClient
+}
+
+func newWriteFreelyPublisher() *writeFreelyPublisher {
+	return &writeFreelyPublisher{
+		baseUrl:    config.WriteFreelyUrl,
+		collection: config.WriteFreelyCollection,
+		token:      os.Getenv("WRITEFREELY_TOKEN"),
+		client:     newRetryClient(),
+	}
+}
+
+// apiUrl returns the URL for the API endpoint in elem, with the (optional)
+// query
+func (w *writeFreelyPublisher) apiUrl(query url.Values, elem ...string) (string, error) {
+	u, err := url.JoinPath(w.baseUrl, append([]string{"api"}, elem...)...)
+	if err != nil {
+		return "", fmt.Errorf("invalid WriteFreely URL: %w", err)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u, nil
+}
+
+// req does a request to WriteFreely API endpoint in elem, decoding the
+// response data in out (if not nil). Returns the status code, so callers can
+// handle e.g.: 404
+func (w *writeFreelyPublisher) req(ctx context.Context, method string, query url.Values, in any, out any, elem ...string) (int, error) {
+	if w.baseUrl == "" || w.collection == "" {
+		return 0, fmt.Errorf("empty writefreely_url or writefreely_collection in config (or WRITEFREELY_URL and WRITEFREELY_COLLECTION environment variables)")
+	}
+	if w.token == "" {
+		return 0, fmt.Errorf("empty WRITEFREELY_TOKEN environment variable")
+	}
+
+	url, err := w.apiUrl(query, elem...)
+	if err != nil {
+		return 0, err
+	}
+
+	header := http.Header{}
+	header.Add("Accept", "application/json")
+	header.Add("Authorization", fmt.Sprintf("Token %s", w.token))
+
+	var body []byte
+	if in != nil {
+		header.Add("Content-Type", "application/json")
+		body, err = json.Marshal(in)
+		if err != nil {
+			return 0, fmt.Errorf("WriteFreely JSON marshal error: %w", err)
+		}
+	}
+
+	r, rBody, err := w.client.do(ctx, method, url, header, body)
+	if err != nil {
+		return 0, fmt.Errorf("WriteFreely request error: %w", err)
+	}
+
+	// e.g.: DELETE returns no content
+	var wr writeFreelyResponse
+	if len(rBody) > 0 {
+		err = json.Unmarshal(rBody, &wr)
+		if err != nil {
+			return r.StatusCode, fmt.Errorf("WriteFreely JSON unmarshal error: %w", err)
+		}
+	}
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return r.StatusCode, fmt.Errorf(
+			"non-2xx (code=%d) status code for url=%s, error: %s",
+			r.StatusCode,
+			url,
+			wr.ErrorMsg,
+		)
+	}
+
+	if out != nil && len(wr.Data) > 0 {
+		err = json.Unmarshal(wr.Data, out)
+		if err != nil {
+			return r.StatusCode, fmt.Errorf("WriteFreely JSON unmarshal error: %w", err)
+		}
+	}
+	return r.StatusCode, nil
+}
+
+func (w *writeFreelyPublisher) getPost(ctx context.Context, slug string) (writeFreelyPost, error) {
+	var p writeFreelyPost
+	code, err := w.req(ctx, "GET", nil, nil, &p, "collections", w.collection, "posts", slug)
+	if code == http.StatusNotFound {
+		return p, errPostNotFound
+	}
+	return p, err
+}
+
+// writeFreelyCreated returns the created field for p. WriteFreely keeps the
+// time, so changing only the time of a post also needs to update it
+func writeFreelyCreated(t time.Time) string {
+	return t.UTC().Format(writeFreelyTimeFormat)
+}
+
+func toWriteFreelyRequest(p post, slug string) writeFreelyPostRequest {
+	return writeFreelyPostRequest{
+		Title:   p.title,
+		Slug:    slug,
+		Body:    string(p.contents),
+		Created: writeFreelyCreated(p.date),
+		Lang:    p.lang,
+	}
+}
+
+func toRemotePost(p writeFreelyPost) remotePost {
+	return remotePost{
+		slug:        p.Slug,
+		title:       p.Title,
+		body:        p.Body,
+		publishedAt: writeFreelyCreated(p.Created),
+	}
+}
+
+func (w *writeFreelyPublisher) Name() string {
+	return "writefreely"
+}
+
+func (w *writeFreelyPublisher) Prepare(ps posts) (posts, error) {
+	blogUrl, err := url.JoinPath(w.baseUrl, w.collection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WriteFreely URL: %w", err)
+	}
+	// WriteFreely also renders Markdown, but we want the same output from
+	// the other publishers (e.g.: syntax highlighting)
+	return renderPosts(ps, blogUrl)
+}
+
+func (w *writeFreelyPublisher) PublishedAt(p post) string {
+	return writeFreelyCreated(p.date)
+}
+
+func (w *writeFreelyPublisher) List(ctx context.Context) ([]remotePost, error) {
+	var list []remotePost
+	seen := map[string]bool{}
+	// The posts are paginated, so keep going until we get an empty page
+	for page := 1; ; page++ {
+		var pl writeFreelyPostList
+		_, err := w.req(
+			ctx,
+			"GET",
+			url.Values{"page": {strconv.Itoa(page)}},
+			nil,
+			&pl,
+			"collections", w.collection, "posts",
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, p := range pl.Posts {
+			if seen[p.Slug] {
+				continue
+			}
+			seen[p.Slug] = true
+			list = append(list, toRemotePost(p))
+			added++
+		}
+		// Also stop if the instance ignored the page parameter and
+		// returned the same posts again
+		if added == 0 {
+			return list, nil
+		}
+	}
+}
+
+func (w *writeFreelyPublisher) Get(ctx context.Context, slug string) (remotePost, error) {
+	p, err := w.getPost(ctx, slug)
+	if err != nil {
+		return remotePost{}, err
+	}
+	return toRemotePost(p), nil
+}
+
+func (w *writeFreelyPublisher) Create(ctx context.Context, post post) (string, error) {
+	var p writeFreelyPost
+	_, err := w.req(
+		ctx,
+		"POST",
+		nil,
+		toWriteFreelyRequest(post, ""),
+		&p,
+		"collections", w.collection, "posts",
+	)
+	return p.Slug, err
+}
+
+func (w *writeFreelyPublisher) Update(ctx context.Context, slug string, post post) error {
+	// Posts are updated by id, not by slug
+	p, err := w.getPost(ctx, slug)
+	if err != nil {
+		return err
+	}
+	_, err = w.req(ctx, "POST", nil, toWriteFreelyRequest(post, post.slug), nil, "posts", p.Id)
+	return err
+}
+
+func (w *writeFreelyPublisher) Delete(ctx context.Context, slug string) error {
+	p, err := w.getPost(ctx, slug)
+	if err != nil {
+		return err
+	}
+	_, err = w.req(ctx, "DELETE", nil, nil, nil, "posts", p.Id)
+	return err
+}
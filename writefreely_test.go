@@ -0,0 +1,222 @@
+package main
+
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//                    Version 2, December 2004
+//
+// Copyright (C) 2024 Thiago Kenji Okada <thiagokokada@gmail.com>
+//
+// Everyone is permitted to copy and distribute verbatim or modified
+// copies of this license document, and changing it is allowed as long
+// as the name is changed.
+//
+//            DO WHAT THE FUCK YOU WANT TO PUBLIC LICENSE
+//   TERMS AND CONDITIONS FOR COPYING, DISTRIBUTION AND MODIFICATION
+//
+//  0. You just DO WHAT THE FUCK YOU WANT TO.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWriteFreely is a stand-in of the WriteFreely API, with the posts of a
+// single collection
+type fakeWriteFreely struct {
+	mu    sync.Mutex
+	posts []writeFreelyPost
+	// Posts per page when listing, 0 ignores the page parameter like some
+	// instances do
+	pageSize int
+	// Pages requested when listing
+	pages []int
+}
+
+func (f *fakeWriteFreely) respond(w http.ResponseWriter, code int, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(writeFreelyResponse{Code: code, Data: raw})
+}
+
+func (f *fakeWriteFreely) find(match func(writeFreelyPost) bool) int {
+	for i, p := range f.posts {
+		if match(p) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeWriteFreely) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/collections/blog/posts", func(w http.ResponseWriter, r *http.Request) {
+		var req writeFreelyPostRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		created, _ := time.Parse(writeFreelyTimeFormat, req.Created)
+		p := writeFreelyPost{
+			Id:      "id-" + strconv.Itoa(len(f.posts)),
+			Slug:    getSlug(req.Title),
+			Title:   req.Title,
+			Body:    req.Body,
+			Created: created,
+		}
+		f.posts = append(f.posts, p)
+		f.respond(w, http.StatusCreated, p)
+	})
+	mux.HandleFunc("GET /api/collections/blog/posts/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		i := f.find(func(p writeFreelyPost) bool { return p.Slug == r.PathValue("slug") })
+		if i < 0 {
+			f.respond(w, http.StatusNotFound, nil)
+			return
+		}
+		f.respond(w, http.StatusOK, f.posts[i])
+	})
+	mux.HandleFunc("GET /api/collections/blog/posts", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		f.pages = append(f.pages, page)
+		posts := f.posts
+		if f.pageSize > 0 {
+			start := min((page-1)*f.pageSize, len(posts))
+			posts = posts[start:min(start+f.pageSize, len(posts))]
+		}
+		f.respond(w, http.StatusOK, writeFreelyPostList{Posts: posts})
+	})
+	mux.HandleFunc("POST /api/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		i := f.find(func(p writeFreelyPost) bool { return p.Id == r.PathValue("id") })
+		if i < 0 {
+			f.respond(w, http.StatusNotFound, nil)
+			return
+		}
+		var req writeFreelyPostRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.posts[i].Title = req.Title
+		f.posts[i].Body = req.Body
+		f.respond(w, http.StatusOK, f.posts[i])
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			f.respond(w, http.StatusUnauthorized, nil)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func newTestWriteFreely(t *testing.T, f *fakeWriteFreely) *writeFreelyPublisher {
+	srv := httptest.NewServer(f.handler())
+	t.Cleanup(srv.Close)
+	return &writeFreelyPublisher{
+		baseUrl:    srv.URL,
+		collection: "blog",
+		token:      "secret",
+		client:     newRetryClient(),
+	}
+}
+
+func TestWriteFreelyCreate(t *testing.T) {
+	f := &fakeWriteFreely{}
+	w := newTestWriteFreely(t, f)
+
+	date := time.Date(2024, 8, 1, 15, 30, 0, 0, time.FixedZone("-03", -3*60*60))
+	slug, err := w.Create(context.Background(), post{title: "Hello world", contents: []byte("<p>Hi</p>"), date: date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug != "hello-world" {
+		t.Errorf("got slug: %s, want: hello-world", slug)
+	}
+	if len(f.posts) != 1 || f.posts[0].Body != "<p>Hi</p>" {
+		t.Fatalf("got posts: %+v, want the created post", f.posts)
+	}
+	if want := date.UTC(); !f.posts[0].Created.Equal(want) {
+		t.Errorf("got created: %v, want: %v", f.posts[0].Created, want)
+	}
+}
+
+func TestWriteFreelyUpdate(t *testing.T) {
+	f := &fakeWriteFreely{posts: []writeFreelyPost{
+		{Id: "other", Slug: "other", Title: "Other"},
+		{Id: "abc", Slug: "hello-world", Title: "Hello world"},
+	}}
+	w := newTestWriteFreely(t, f)
+
+	err := w.Update(context.Background(), "hello-world", post{title: "Hello again", slug: "hello-world", contents: []byte("<p>Updated</p>")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Found by slug, but updated by id
+	if got := f.posts[1]; got.Title != "Hello again" || got.Body != "<p>Updated</p>" {
+		t.Errorf("got post: %+v, want it updated", got)
+	}
+	if got := f.posts[0]; got.Title != "Other" {
+		t.Errorf("got post: %+v, want it unchanged", got)
+	}
+}
+
+func TestWriteFreelyNotFound(t *testing.T) {
+	w := newTestWriteFreely(t, &fakeWriteFreely{})
+	ctx := context.Background()
+
+	_, err := w.Get(ctx, "missing")
+	if !errors.Is(err, errPostNotFound) {
+		t.Errorf("Get: got error: %v, want: %v", err, errPostNotFound)
+	}
+	err = w.Update(ctx, "missing", post{title: "Missing"})
+	if !errors.Is(err, errPostNotFound) {
+		t.Errorf("Update: got error: %v, want: %v", err, errPostNotFound)
+	}
+}
+
+func TestWriteFreelyList(t *testing.T) {
+	var posts []writeFreelyPost
+	for i := range 5 {
+		s := strconv.Itoa(i)
+		posts = append(posts, writeFreelyPost{Id: s, Slug: "post-" + s})
+	}
+
+	for _, tt := range []struct {
+		name      string
+		pageSize  int
+		wantPages []int
+	}{
+		// Stops on the first empty page
+		{"paginated", 2, []int{1, 2, 3, 4}},
+		// Stops once the same posts are returned again
+		{"ignores page", 0, []int{1, 2}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeWriteFreely{posts: posts, pageSize: tt.pageSize}
+			w := newTestWriteFreely(t, f)
+
+			list, err := w.List(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != len(posts) {
+				t.Errorf("got %d posts, want: %d", len(list), len(posts))
+			}
+			for i, p := range list {
+				if want := posts[i].Slug; p.slug != want {
+					t.Errorf("got slug: %s, want: %s", p.slug, want)
+				}
+			}
+			if !slices.Equal(f.pages, tt.wantPages) {
+				t.Errorf("got pages: %v, want: %v", f.pages, tt.wantPages)
+			}
+		})
+	}
+}